@@ -0,0 +1,249 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// bootCheckCleanupTimeout limits removing the virtual machine of a boot
+// check, which happens even when the check itself timed out.
+const bootCheckCleanupTimeout = 5 * time.Minute
+
+// bootCheck configures the verify_bootable check of an uploaded ISO: a
+// throwaway virtual machine boots from it, and must still be running with the
+// CD-ROM connected after bootWait.
+type bootCheck struct {
+	host         string
+	resourcePool string
+	network      string
+	guestID      string
+	memory       int
+	bootWait     time.Duration
+	timeout      time.Duration
+}
+
+// expandBootCheck reads a verify_bootable block, returning nil if there is
+// none.
+func expandBootCheck(v interface{}) *bootCheck {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+
+	// Both are validated by the schema
+	bootWait, _ := time.ParseDuration(m["boot_wait"].(string))
+	timeout, _ := time.ParseDuration(m["timeout"].(string))
+
+	return &bootCheck{
+		host:         m["host"].(string),
+		resourcePool: m["resource_pool"].(string),
+		network:      m["network"].(string),
+		guestID:      m["guest_id"].(string),
+		memory:       m["memory"].(int),
+		bootWait:     bootWait,
+		timeout:      timeout,
+	}
+}
+
+// verifyBootable boots a throwaway virtual machine from the uploaded ISO
+// of f, and removes it again.
+func verifyBootable(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, f *file) error {
+	c := f.verifyBootable
+	iso := ds.Path(f.destinationFile)
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	var host *object.HostSystem
+	var err error
+	if c.host != "" {
+		host, err = finder.HostSystem(ctx, c.host)
+		if err != nil {
+			return fmt.Errorf("error finding host %s: %s", c.host, err)
+		}
+	}
+
+	var pool *object.ResourcePool
+	switch {
+	case c.resourcePool != "":
+		pool, err = finder.ResourcePool(ctx, c.resourcePool)
+	case host != nil:
+		pool, err = host.ResourcePool(ctx)
+	default:
+		pool, err = finder.DefaultResourcePool(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error finding resource pool of the boot check: %s", err)
+	}
+
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading folders of datacenter: %s", err)
+	}
+
+	spec, err := bootCheckSpec(ctx, finder, c, ds, iso)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] creating vm %s to boot %s", spec.Name, iso)
+	task, err := folders.VmFolder.CreateVM(ctx, spec, pool, host)
+	if err != nil {
+		return fmt.Errorf("error creating vm to boot %s: %s", iso, err)
+	}
+	info, err := waitForTask(ctx, task, nil)
+	if err != nil {
+		return fmt.Errorf("error creating vm to boot %s: %s", iso, err)
+	}
+
+	vm := object.NewVirtualMachine(client.Client, info.Result.(types.ManagedObjectReference))
+	defer removeBootCheckVM(vm, spec.Name)
+
+	task, err = vm.PowerOn(ctx)
+	if err == nil {
+		_, err = waitForTask(ctx, task, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("vm %s could not be powered on from %s: %s", spec.Name, iso, err)
+	}
+
+	select {
+	case <-time.After(c.bootWait):
+	case <-ctx.Done():
+		return fmt.Errorf("timeout while booting %s: %s", iso, ctx.Err())
+	}
+
+	var mvm mo.VirtualMachine
+	collector := property.DefaultCollector(client.Client)
+	err = collector.RetrieveOne(ctx, vm.Reference(), []string{"name", "runtime", "config.hardware.device"}, &mvm)
+	if err != nil {
+		return fmt.Errorf("error reading state of vm %s: %s", spec.Name, err)
+	}
+
+	err = bootCheckError(mvm, iso)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] %s booted in vm %s", iso, spec.Name)
+	return nil
+}
+
+// bootCheckSpec returns the configuration of a virtual machine without disks
+// that boots from the CD-ROM holding iso.
+func bootCheckSpec(ctx context.Context, finder *find.Finder, c *bootCheck, ds *object.Datastore, iso string) (types.VirtualMachineConfigSpec, error) {
+	var spec types.VirtualMachineConfigSpec
+	var devices object.VirtualDeviceList
+
+	ide, err := devices.CreateIDEController()
+	if err != nil {
+		return spec, err
+	}
+	devices = append(devices, ide)
+
+	cdrom, err := devices.CreateCdrom(ide.(*types.VirtualIDEController))
+	if err != nil {
+		return spec, err
+	}
+	devices = append(devices, devices.InsertIso(cdrom, iso))
+
+	if c.network != "" {
+		network, err := finder.Network(ctx, c.network)
+		if err != nil {
+			return spec, fmt.Errorf("error finding network %s: %s", c.network, err)
+		}
+		backing, err := network.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return spec, fmt.Errorf("error reading network %s: %s", c.network, err)
+		}
+		nic, err := devices.CreateEthernetCard("e1000", backing)
+		if err != nil {
+			return spec, err
+		}
+		devices = append(devices, nic)
+	}
+
+	deviceChange, err := devices.ConfigSpec(types.VirtualDeviceConfigSpecOperationAdd)
+	if err != nil {
+		return spec, err
+	}
+
+	spec = types.VirtualMachineConfigSpec{
+		Name:         fmt.Sprintf("terraform-boot-check-%d", time.Now().UnixNano()),
+		GuestId:      c.guestID,
+		NumCPUs:      1,
+		MemoryMB:     int64(c.memory),
+		Files:        &types.VirtualMachineFileInfo{VmPathName: fmt.Sprintf("[%s]", ds.Name())},
+		DeviceChange: deviceChange,
+		BootOptions: &types.VirtualMachineBootOptions{
+			BootOrder: devices.BootOrder([]string{object.DeviceTypeCdrom}),
+		},
+	}
+	return spec, nil
+}
+
+// bootCheckError returns why the virtual machine mvm, booted from iso, did
+// not pass the boot check, or nil if it did. Whether the guest operating
+// system started can't be seen without VMware Tools; a virtual machine that
+// is still running with the ISO connected, and isn't stuck on a question
+// such as the ISO being unreadable, is taken as booted.
+func bootCheckError(mvm mo.VirtualMachine, iso string) error {
+	if q := mvm.Runtime.Question; q != nil {
+		return fmt.Errorf("booting %s is blocked by a question of vm %s: %s", iso, mvm.Name, q.Text)
+	}
+
+	if mvm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+		return fmt.Errorf("vm %s booted from %s is %s", mvm.Name, iso, mvm.Runtime.PowerState)
+	}
+
+	if mvm.Config != nil {
+		for _, device := range mvm.Config.Hardware.Device {
+			cdrom, ok := device.(*types.VirtualCdrom)
+			if !ok {
+				continue
+			}
+			if cdrom.Connectable != nil && !cdrom.Connectable.Connected {
+				return fmt.Errorf("vm %s could not connect %s", mvm.Name, iso)
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeBootCheckVM powers off and destroys the virtual machine of a boot
+// check. It is only logged if that fails, so the result of the check isn't
+// hidden.
+func removeBootCheckVM(vm *object.VirtualMachine, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), bootCheckCleanupTimeout)
+	defer cancel()
+
+	// Powering off fails if the vm is already off, which is fine
+	task, err := vm.PowerOff(ctx)
+	if err == nil {
+		waitForTask(ctx, task, nil)
+	}
+
+	task, err = vm.Destroy(ctx)
+	if err == nil {
+		_, err = waitForTask(ctx, task, nil)
+	}
+	if err != nil {
+		log.Printf("[WARN] unable to remove boot check vm %s, it must be removed manually: %s", name, err)
+		return
+	}
+	log.Printf("[DEBUG] removed boot check vm %s", name)
+}
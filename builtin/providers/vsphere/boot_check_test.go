@@ -0,0 +1,74 @@
+package vsphere
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestExpandBootCheck(t *testing.T) {
+	if c := expandBootCheck([]interface{}{}); c != nil {
+		t.Fatalf("expected no boot check, got %#v", c)
+	}
+
+	c := expandBootCheck([]interface{}{
+		map[string]interface{}{
+			"host":          "esxi1.example.com",
+			"resource_pool": "",
+			"network":       "VM Network",
+			"guest_id":      "otherGuest64",
+			"memory":        512,
+			"boot_wait":     "45s",
+			"timeout":       "10m",
+		},
+	})
+	if c.host != "esxi1.example.com" || c.network != "VM Network" || c.memory != 512 {
+		t.Fatalf("unexpected boot check %#v", c)
+	}
+	if c.bootWait != 45*time.Second || c.timeout != 10*time.Minute {
+		t.Fatalf("unexpected durations %s and %s", c.bootWait, c.timeout)
+	}
+}
+
+func TestBootCheckError(t *testing.T) {
+	booted := func() mo.VirtualMachine {
+		var mvm mo.VirtualMachine
+		mvm.Name = "terraform-boot-check-1"
+		mvm.Runtime.PowerState = types.VirtualMachinePowerStatePoweredOn
+		mvm.Config = &types.VirtualMachineConfigInfo{}
+		mvm.Config.Hardware.Device = []types.BaseVirtualDevice{
+			&types.VirtualCdrom{
+				VirtualDevice: types.VirtualDevice{
+					Connectable: &types.VirtualDeviceConnectInfo{Connected: true},
+				},
+			},
+		}
+		return mvm
+	}
+	iso := "[local] iso/ubuntu.iso"
+
+	if err := bootCheckError(booted(), iso); err != nil {
+		t.Fatalf("expected the check to pass, got %s", err)
+	}
+
+	off := booted()
+	off.Runtime.PowerState = types.VirtualMachinePowerStatePoweredOff
+	if err := bootCheckError(off, iso); err == nil {
+		t.Fatal("expected a powered off vm to fail the check")
+	}
+
+	question := booted()
+	question.Runtime.Question = &types.VirtualMachineQuestionInfo{Text: "The operation on file failed."}
+	expected := "booting [local] iso/ubuntu.iso is blocked by a question of vm terraform-boot-check-1: The operation on file failed."
+	if err := bootCheckError(question, iso); err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+
+	disconnected := booted()
+	disconnected.Config.Hardware.Device[0].GetVirtualDevice().Connectable.Connected = false
+	if err := bootCheckError(disconnected, iso); err == nil {
+		t.Fatal("expected a disconnected CD-ROM to fail the check")
+	}
+}
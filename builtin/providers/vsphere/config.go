@@ -3,24 +3,125 @@ package vsphere
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/terraform/terraform"
 	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/debug"
+	"github.com/vmware/govmomi/vim25/soap"
 	"golang.org/x/net/context"
 )
 
 type Config struct {
-	User          string
-	Password      string
-	VSphereServer string
-	InsecureFlag  bool
-	Debug         bool
-	DebugPath     string
-	DebugPathRun  string
+	User                string
+	Password            string
+	VSphereServer       string
+	InsecureFlag        bool
+	Debug               bool
+	DebugPath           string
+	DebugPathRun        string
+	KeepAlive           time.Duration
+	Timeout             time.Duration
+	UserAgent           string
+	ReadOnly            bool
+	TaskPollInterval    time.Duration
+	TaskPollMaxInterval time.Duration
+
+	// Credentials, if set, are logged in with instead of User and
+	// Password.
+	Credentials CredentialProvider
+}
+
+// credentials returns where the client reads its credentials from.
+func (c *Config) credentials() CredentialProvider {
+	if c.Credentials != nil {
+		return c.Credentials
+	}
+	return staticCredentials{user: c.User, password: c.Password}
+}
+
+// clientSettings holds the provider settings resources need at run time.
+// They are kept per client, like the lookup cache, so that meta stays the
+// *govmomi.Client every resource already expects. The key is the underlying
+// vim25 client, so that helpers only holding a managed object can find them.
+type clientSettings struct {
+	readOnly bool
+	taskPoll taskPollConfig
+
+	// config is the configuration the client was created with, used to
+	// log in to the same server as another user.
+	config Config
+}
+
+var (
+	clientSettingsLock sync.Mutex
+	clientSettingsMap  = map[*vim25.Client]clientSettings{}
+)
+
+func setClientSettings(c *vim25.Client, s clientSettings) {
+	clientSettingsLock.Lock()
+	defer clientSettingsLock.Unlock()
+	clientSettingsMap[c] = s
+}
+
+func getClientSettings(c *vim25.Client) clientSettings {
+	clientSettingsLock.Lock()
+	defer clientSettingsLock.Unlock()
+	return clientSettingsMap[c]
+}
+
+func deleteClientSettings(c *vim25.Client) {
+	clientSettingsLock.Lock()
+	defer clientSettingsLock.Unlock()
+	delete(clientSettingsMap, c)
+}
+
+// checkWritable returns an error when the provider is configured with
+// read_only, so that mutating operations fail before they change anything.
+func checkWritable(meta interface{}, op string) error {
+	if getClientSettings(meta.(*govmomi.Client).Client).readOnly {
+		return fmt.Errorf("cannot %s: the provider is configured with read_only", op)
+	}
+	return nil
+}
+
+// clientConfig returns the configuration the provider's client c was
+// created with, to derive the configuration of a dedicated session from.
+func clientConfig(c *govmomi.Client) Config {
+	return getClientSettings(c.Client).config
+}
+
+// newSession logs in with config and returns the client, and a function
+// that logs it out again. The caller must call it once done, also on errors,
+// so the session doesn't linger until it times out.
+func newSession(config Config) (*govmomi.Client, func(), error) {
+	// Debug logging is set up globally, by the provider's own client.
+	config.Debug = false
+
+	client, err := config.Client()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error logging in to %s as %s: %s", config.VSphereServer, config.User, err)
+	}
+	log.Printf("[DEBUG] Logged in to %s as %s", config.VSphereServer, config.User)
+
+	logout := func() {
+		err := client.Logout(context.TODO())
+		if err != nil {
+			log.Printf("[WARN] error logging out %s from %s: %s", config.User, config.VSphereServer, err)
+		} else {
+			log.Printf("[DEBUG] Logged out %s from %s", config.User, config.VSphereServer)
+		}
+		deleteClientSettings(client.Client)
+		deleteLookupCache(client)
+	}
+	return client, logout, nil
 }
 
 // Client() returns a new client for accessing VMWare vSphere.
@@ -30,23 +131,106 @@ func (c *Config) Client() (*govmomi.Client, error) {
 		return nil, fmt.Errorf("Error parse url: %s", err)
 	}
 
-	u.User = url.UserPassword(c.User, c.Password)
+	credentials := c.credentials()
+	user, password, err := credentials.Credentials()
+	if err != nil {
+		return nil, err
+	}
 
 	err = c.EnableDebug()
 	if err != nil {
 		return nil, fmt.Errorf("Error setting up client debug: %s", err)
 	}
 
-	client, err := govmomi.NewClient(context.TODO(), u, c.InsecureFlag)
+	soapClient := soap.NewClient(u, c.InsecureFlag)
+	soapClient.Timeout = c.Timeout
+	soapClient.Transport = &userAgentTransport{
+		RoundTripper: soapClient.Transport,
+		userAgent:    userAgent(c.UserAgent),
+	}
+
+	vimClient, err := vim25.NewClient(context.TODO(), soapClient)
+	if err != nil {
+		return nil, fmt.Errorf("Error setting up client: %s", err)
+	}
+
+	// Keep the session alive during long operations such as file uploads,
+	// which don't use it.
+	if c.KeepAlive > 0 {
+		vimClient.RoundTripper = session.KeepAlive(vimClient.RoundTripper, c.KeepAlive)
+	}
+
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+
+	// Log in again when the session expires, with credentials read again
+	// in case the old ones have been rotated.
+	vimClient.RoundTripper = newReauthRoundTripper(vimClient.RoundTripper, func(ctx context.Context) error {
+		user, password, err := credentials.Credentials()
+		if err != nil {
+			return err
+		}
+		return client.Login(ctx, url.UserPassword(user, password))
+	})
+
+	err = client.Login(context.TODO(), url.UserPassword(user, password))
 	if err != nil {
 		return nil, fmt.Errorf("Error setting up client: %s", err)
 	}
 
+	// The user is kept for log messages of dedicated sessions
+	config := *c
+	config.User = user
+	setClientSettings(vimClient, clientSettings{
+		readOnly: c.ReadOnly,
+		taskPoll: taskPollConfig{
+			interval:    c.TaskPollInterval,
+			maxInterval: c.TaskPollMaxInterval,
+		},
+		config: config,
+	})
+
 	log.Printf("[INFO] VMWare vSphere Client configured for URL: %s", c.VSphereServer)
 
 	return client, nil
 }
 
+// userAgent returns the User-Agent of requests to vSphere: the Terraform
+// version, preceded by custom if set.
+func userAgent(custom string) string {
+	version := terraform.Version
+	if terraform.VersionPrerelease != "" {
+		version = fmt.Sprintf("%s-%s", terraform.Version, terraform.VersionPrerelease)
+	}
+
+	ua := fmt.Sprintf("HashiCorp-Terraform-v%s", version)
+	if custom != "" {
+		ua = custom + " " + ua
+	}
+	return ua
+}
+
+// userAgentTransport sets the User-Agent header of every request, which the
+// vendored soap client has no option for.
+type userAgentTransport struct {
+	http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request, so set the header on a copy
+	r := *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("User-Agent", t.userAgent)
+
+	return t.RoundTripper.RoundTrip(&r)
+}
+
 func (c *Config) EnableDebug() error {
 	if !c.Debug {
 		return nil
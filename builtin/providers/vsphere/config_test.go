@@ -0,0 +1,51 @@
+package vsphere
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	if ua := userAgent(""); !strings.HasPrefix(ua, "HashiCorp-Terraform-v") {
+		t.Fatalf("unexpected User-Agent %q", ua)
+	}
+	if ua := userAgent("ci-pipeline/1.0"); !strings.HasPrefix(ua, "ci-pipeline/1.0 HashiCorp-Terraform-v") {
+		t.Fatalf("unexpected User-Agent %q", ua)
+	}
+}
+
+func TestUserAgentTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &userAgentTransport{
+			RoundTripper: http.DefaultTransport,
+			userAgent:    "ci-pipeline/1.0",
+		},
+	}
+
+	req, err := http.NewRequest("POST", server.URL+"/sdk", nil)
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	res.Body.Close()
+
+	if received != "ci-pipeline/1.0" {
+		t.Fatalf("expected User-Agent %q, got %q", "ci-pipeline/1.0", received)
+	}
+	if req.Header.Get("User-Agent") != "Go-http-client/1.1" {
+		t.Fatal("the original request was modified")
+	}
+}
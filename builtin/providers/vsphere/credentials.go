@@ -0,0 +1,51 @@
+package vsphere
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// CredentialProvider returns the user name and password the client logs in
+// with. It is asked again whenever the session has expired, so credentials
+// that rotate during a long apply are picked up by the next login.
+type CredentialProvider interface {
+	Credentials() (user, password string, err error)
+}
+
+// staticCredentials are the user and password of the provider
+// configuration, which never change.
+type staticCredentials struct {
+	user     string
+	password string
+}
+
+func (c staticCredentials) Credentials() (string, string, error) {
+	return c.user, c.password, nil
+}
+
+// fileCredentials reads the credentials from a JSON file with the keys user
+// and password every time they are needed. Whatever rotates them, e.g. a
+// Vault agent template, only has to rewrite the file.
+type fileCredentials struct {
+	path string
+}
+
+func (c fileCredentials) Credentials() (string, string, error) {
+	b, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading credentials_file: %s", err)
+	}
+
+	var creds struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return "", "", fmt.Errorf("error parsing credentials_file %s: %s", c.path, err)
+	}
+	if creds.User == "" || creds.Password == "" {
+		return "", "", fmt.Errorf("credentials_file %s must set both user and password", c.path)
+	}
+	return creds.User, creds.Password, nil
+}
@@ -0,0 +1,61 @@
+package vsphere
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileCredentials(t *testing.T) {
+	f, err := ioutil.TempFile("", "tf-vsphere-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	c := fileCredentials{path: f.Name()}
+
+	write := func(content string) {
+		if err := ioutil.WriteFile(f.Name(), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"user": "terraform@vsphere.local", "password": "first"}`)
+	user, password, err := c.Credentials()
+	if err != nil || user != "terraform@vsphere.local" || password != "first" {
+		t.Fatalf("unexpected credentials %q, %q, %v", user, password, err)
+	}
+
+	// Rotated credentials are read again
+	write(`{"user": "terraform@vsphere.local", "password": "second"}`)
+	_, password, err = c.Credentials()
+	if err != nil || password != "second" {
+		t.Fatalf("expected the rotated password, got %q, %v", password, err)
+	}
+
+	write(`{"user": "terraform@vsphere.local"}`)
+	if _, _, err := c.Credentials(); err == nil {
+		t.Fatal("expected an error without a password")
+	}
+
+	write(`user=terraform`)
+	if _, _, err := c.Credentials(); err == nil {
+		t.Fatal("expected an error for a file that isn't JSON")
+	}
+}
+
+func TestConfigCredentials(t *testing.T) {
+	c := Config{User: "root", Password: "vmware"}
+	user, password, _ := c.credentials().Credentials()
+	if user != "root" || password != "vmware" {
+		t.Fatalf("expected the static credentials, got %q and %q", user, password)
+	}
+
+	c.Credentials = staticCredentials{user: "other", password: "secret"}
+	user, _, _ = c.credentials().Credentials()
+	if user != "other" {
+		t.Fatalf("expected the credential provider to be used, got %q", user)
+	}
+}
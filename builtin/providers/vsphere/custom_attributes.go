@@ -0,0 +1,130 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// setCustomAttributes changes the custom attributes of the datastore with
+// the managed object ID datastoreID from old to new: names only in old are
+// cleared, and names in new are set. Names without a custom field definition
+// are an error, unless create is set to add the definition for datastores.
+func setCustomAttributes(ctx context.Context, client *govmomi.Client, datastoreID string, old, new map[string]interface{}, create bool) error {
+	if len(old) == 0 && len(new) == 0 {
+		return nil
+	}
+
+	if !client.IsVC() {
+		return fmt.Errorf("custom attributes require vCenter, %s is an ESXi host", client.URL().Host)
+	}
+	m, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return fmt.Errorf("error reading custom attributes: %s", err)
+	}
+
+	fields, err := m.Field(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading custom attribute definitions: %s", err)
+	}
+	keys := customFieldKeys(fields)
+
+	entity := types.ManagedObjectReference{Type: "Datastore", Value: datastoreID}
+	for name := range old {
+		if _, ok := new[name]; ok {
+			continue
+		}
+		key, ok := keys[name]
+		if !ok {
+			// Nothing to clear if the definition is gone
+			continue
+		}
+		log.Printf("[DEBUG] clearing custom attribute %s of datastore %s", name, datastoreID)
+		if err := m.Set(ctx, entity, key, ""); err != nil {
+			return fmt.Errorf("error clearing custom attribute %s: %s", name, err)
+		}
+	}
+
+	for name, value := range new {
+		key, ok := keys[name]
+		if !ok {
+			if !create {
+				return fmt.Errorf("custom attribute %s does not exist, define it in vCenter or set create_custom_attributes", name)
+			}
+			def, err := m.Add(ctx, name, "Datastore", nil, nil)
+			if err != nil {
+				return fmt.Errorf("error creating custom attribute %s: %s", name, err)
+			}
+			log.Printf("[INFO] created custom attribute %s", name)
+			key = def.Key
+		}
+		log.Printf("[DEBUG] setting custom attribute %s of datastore %s", name, datastoreID)
+		if err := m.Set(ctx, entity, key, value.(string)); err != nil {
+			return fmt.Errorf("error setting custom attribute %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// readCustomAttributes returns the current values of the custom attributes
+// of ds that are named in configured. Names that aren't set, or have no
+// definition, have an empty value.
+func readCustomAttributes(ctx context.Context, client *govmomi.Client, ds *object.Datastore, configured map[string]interface{}) (map[string]interface{}, error) {
+	if !client.IsVC() {
+		return nil, fmt.Errorf("custom attributes require vCenter, %s is an ESXi host", client.URL().Host)
+	}
+	m, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return nil, fmt.Errorf("error reading custom attributes: %s", err)
+	}
+
+	fields, err := m.Field(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading custom attribute definitions: %s", err)
+	}
+
+	var mds mo.Datastore
+	collector := property.DefaultCollector(client.Client)
+	err = collector.RetrieveOne(ctx, ds.Reference(), []string{"customValue"}, &mds)
+	if err != nil {
+		return nil, fmt.Errorf("error reading custom attributes of datastore %s: %s", ds.Name(), err)
+	}
+
+	return customAttributeValues(fields, mds.CustomValue, configured), nil
+}
+
+// customFieldKeys maps the names of custom field definitions to their keys.
+func customFieldKeys(fields []types.CustomFieldDef) map[string]int32 {
+	keys := make(map[string]int32)
+	for _, field := range fields {
+		keys[field.Name] = field.Key
+	}
+	return keys
+}
+
+// customAttributeValues returns the values of the configured custom
+// attributes among the values of an entity.
+func customAttributeValues(fields []types.CustomFieldDef, values []types.BaseCustomFieldValue, configured map[string]interface{}) map[string]interface{} {
+	byKey := make(map[int32]string)
+	for _, v := range values {
+		if s, ok := v.(*types.CustomFieldStringValue); ok {
+			byKey[s.Key] = s.Value
+		}
+	}
+
+	keys := customFieldKeys(fields)
+	result := make(map[string]interface{})
+	for name := range configured {
+		result[name] = ""
+		if key, ok := keys[name]; ok {
+			result[name] = byKey[key]
+		}
+	}
+	return result
+}
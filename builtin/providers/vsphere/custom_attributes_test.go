@@ -0,0 +1,34 @@
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestCustomAttributeValues(t *testing.T) {
+	fields := []types.CustomFieldDef{
+		{Key: 101, Name: "deployed-by"},
+		{Key: 102, Name: "ticket"},
+		{Key: 103, Name: "owner"},
+	}
+	values := []types.BaseCustomFieldValue{
+		&types.CustomFieldStringValue{CustomFieldValue: types.CustomFieldValue{Key: 101}, Value: "terraform"},
+		&types.CustomFieldStringValue{CustomFieldValue: types.CustomFieldValue{Key: 103}, Value: "storage-team"},
+	}
+	configured := map[string]interface{}{
+		"deployed-by": "terraform",
+		"ticket":      "OPS-1234",
+		"undefined":   "x",
+	}
+
+	expected := map[string]interface{}{
+		"deployed-by": "terraform",
+		"ticket":      "",
+		"undefined":   "",
+	}
+	if actual := customAttributeValues(fields, values, configured); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
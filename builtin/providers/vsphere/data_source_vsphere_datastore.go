@@ -0,0 +1,99 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereDatastore() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereDatastoreRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"capacity": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"free_space": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceVSphereDatastoreRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	name := d.Get("name").(string)
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	ds, err := lookupDatastore(client, dc, name)
+	if err != nil {
+		if isDatastoreNotFoundError(err) && isDatastoreCluster(client, dc, name) {
+			return fmt.Errorf("%q is a datastore cluster, set name to one of its member datastores instead", name)
+		}
+		return fmt.Errorf("error %s", err)
+	}
+
+	log.Printf("[DEBUG] Reading datastore: %s", name)
+
+	summary, err := datastoreSummary(context.TODO(), client, ds)
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	d.SetId(ds.Reference().Value)
+	d.Set("capacity", int(summary.Capacity))
+	d.Set("free_space", int(summary.FreeSpace))
+	d.Set("type", summary.Type)
+
+	return nil
+}
+
+// datastoreSummary retrieves the summary of a datastore, which holds its
+// capacity and free space.
+func datastoreSummary(ctx context.Context, client *govmomi.Client, ds *object.Datastore) (types.DatastoreSummary, error) {
+	var mds mo.Datastore
+	collector := property.DefaultCollector(client.Client)
+	err := collector.RetrieveOne(ctx, ds.Reference(), []string{"summary"}, &mds)
+	return mds.Summary, err
+}
+
+// isDatastoreCluster reports whether name refers to a datastore cluster in
+// the datacenter dc.
+func isDatastoreCluster(client *govmomi.Client, dc *object.Datacenter, name string) bool {
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	_, err := finder.DatastoreCluster(context.TODO(), inventoryPattern(name))
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereDatastoreByAttribute() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereDatastoreByAttributeRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"attribute": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"value": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceVSphereDatastoreByAttributeRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	attribute := d.Get("attribute").(string)
+	value := d.Get("value").(string)
+	ctx := context.TODO()
+
+	if !client.IsVC() {
+		return fmt.Errorf("custom attributes require vCenter, %s is an ESXi host", client.URL().Host)
+	}
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error finding datacenter: %s", err)
+	}
+
+	cfm, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return fmt.Errorf("error reading custom attributes: %s", err)
+	}
+	key, err := cfm.FindKey(ctx, attribute)
+	if err != nil {
+		if err == object.ErrKeyNameNotFound {
+			return fmt.Errorf("custom attribute %q is not defined", attribute)
+		}
+		return fmt.Errorf("error reading custom attribute %q: %s", attribute, err)
+	}
+
+	log.Printf("[DEBUG] Finding datastores with %s = %s", attribute, value)
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+	dss, err := finder.DatastoreList(ctx, "*")
+	if err != nil {
+		return fmt.Errorf("error listing datastores: %s", err)
+	}
+
+	var refs []types.ManagedObjectReference
+	for _, ds := range dss {
+		refs = append(refs, ds.Reference())
+	}
+	var mdss []mo.Datastore
+	collector := property.DefaultCollector(client.Client)
+	err = collector.Retrieve(ctx, refs, []string{"name", "customValue"}, &mdss)
+	if err != nil {
+		return fmt.Errorf("error reading datastore attributes: %s", err)
+	}
+
+	matches := datastoresWithAttribute(mdss, key, value)
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no datastore has %s = %q", attribute, value)
+	case 1:
+	default:
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%d datastores have %s = %q: %s", len(matches), attribute, value, strings.Join(names, ", "))
+	}
+
+	d.SetId(matches[0].Reference().Value)
+	d.Set("name", matches[0].Name)
+
+	return nil
+}
+
+// datastoresWithAttribute returns the datastores whose custom attribute key
+// is set to value.
+func datastoresWithAttribute(dss []mo.Datastore, key int32, value string) []mo.Datastore {
+	var matches []mo.Datastore
+	for _, ds := range dss {
+		for _, cv := range ds.CustomValue {
+			v, ok := cv.(*types.CustomFieldStringValue)
+			if ok && v.Key == key && v.Value == value {
+				matches = append(matches, ds)
+				break
+			}
+		}
+	}
+	return matches
+}
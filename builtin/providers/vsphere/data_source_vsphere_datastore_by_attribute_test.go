@@ -0,0 +1,51 @@
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDatastoresWithAttribute(t *testing.T) {
+	value := func(key int32, v string) types.BaseCustomFieldValue {
+		return &types.CustomFieldStringValue{
+			CustomFieldValue: types.CustomFieldValue{Key: key},
+			Value:            v,
+		}
+	}
+	dss := []mo.Datastore{
+		{Name: "iso-store"},
+		{Name: "vm-store"},
+		{Name: "scratch"},
+	}
+	dss[0].CustomValue = []types.BaseCustomFieldValue{value(101, "iso-store"), value(102, "prod")}
+	dss[1].CustomValue = []types.BaseCustomFieldValue{value(101, "vms"), value(102, "prod")}
+
+	cases := []struct {
+		key      int32
+		value    string
+		expected []string
+	}{
+		{101, "iso-store", []string{"iso-store"}},
+		{102, "prod", []string{"iso-store", "vm-store"}},
+		{102, "iso-store", nil},
+		{103, "prod", nil},
+	}
+
+	for _, tc := range cases {
+		var actual []string
+		for _, ds := range datastoresWithAttribute(dss, tc.key, tc.value) {
+			actual = append(actual, ds.Name)
+		}
+		if len(actual) != len(tc.expected) {
+			t.Errorf("key %d = %q: expected %v, got %v", tc.key, tc.value, tc.expected, actual)
+			continue
+		}
+		for i := range actual {
+			if actual[i] != tc.expected[i] {
+				t.Errorf("key %d = %q: expected %v, got %v", tc.key, tc.value, tc.expected, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereDatastoreFile() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereDatastoreFileRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"exists": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"last_modified": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"owner": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceVSphereDatastoreFileRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	filePath := d.Get("path").(string)
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	ds, err := lookupDatastore(client, dc, d.Get("datastore").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	log.Printf("[DEBUG] Reading datastore file: %s", ds.Path(filePath))
+	d.SetId(ds.Path(filePath))
+
+	info, err := ds.Stat(context.TODO(), filePath)
+	if err != nil {
+		if !isFileNotFoundError(err) {
+			return err
+		}
+		log.Printf("[DEBUG] Datastore file %s does not exist", ds.Path(filePath))
+		d.Set("exists", false)
+		d.Set("size", 0)
+		d.Set("last_modified", "")
+		d.Set("owner", "")
+		return nil
+	}
+
+	fi := info.GetFileInfo()
+	d.Set("exists", true)
+	d.Set("size", int(fi.FileSize))
+	d.Set("last_modified", fileModification(fi))
+	d.Set("owner", fi.Owner)
+
+	return nil
+}
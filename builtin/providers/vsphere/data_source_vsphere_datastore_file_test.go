@@ -0,0 +1,69 @@
+package vsphere
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccVSphereDatastoreFile_basic(t *testing.T) {
+	testVmdkFileData := []byte("# Disk DescriptorFile\n")
+	testVmdkFile := "/tmp/tf_test.vmdk"
+	err := ioutil.WriteFile(testVmdkFile, testVmdkFileData, 0644)
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDatastoreFileConfig,
+					datacenter,
+					datastore,
+					testVmdkFile,
+					datacenter,
+					datastore,
+					datacenter,
+					datastore,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.vsphere_datastore_file.uploaded", "exists", "true"),
+					resource.TestCheckResourceAttr("data.vsphere_datastore_file.uploaded", "size", fmt.Sprintf("%d", len(testVmdkFileData))),
+					resource.TestCheckResourceAttr("data.vsphere_datastore_file.missing", "exists", "false"),
+				),
+			},
+		},
+	})
+	os.Remove(testVmdkFile)
+}
+
+const testAccCheckVSphereDatastoreFileConfig = `
+resource "vsphere_file" "upload" {
+	datacenter = "%s"
+	datastore = "%s"
+	source_file = "%s"
+	destination_file = "tf_file_test.vmdk"
+}
+
+data "vsphere_datastore_file" "uploaded" {
+	datacenter = "%s"
+	datastore = "%s"
+	path = "${vsphere_file.upload.destination_file}"
+}
+
+data "vsphere_datastore_file" "missing" {
+	datacenter = "%s"
+	datastore = "%s"
+	path = "tf_file_test_missing.vmdk"
+}
+`
@@ -0,0 +1,175 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereDatastoreFiles() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereDatastoreFilesRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"glob": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"recursive": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"files": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"path": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"size": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+
+						"last_modified": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceVSphereDatastoreFilesRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	directory := normalizeDatastorePath(d.Get("path").(string))
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	ds, err := lookupDatastore(client, dc, d.Get("datastore").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	log.Printf("[DEBUG] Listing datastore directory: %s", ds.Path(directory))
+
+	files, err := findDatastoreFiles(context.TODO(), ds, directory, d.Get("glob").(string), d.Get("recursive").(bool))
+	if err != nil {
+		return fmt.Errorf("error listing %s: %s", ds.Path(directory), err)
+	}
+
+	d.SetId(ds.Path(directory))
+	d.Set("files", files)
+
+	return nil
+}
+
+// findDatastoreFiles returns the files in a datastore directory, and in its
+// subdirectories if recursive is set, whose names match glob. Directories
+// themselves are not returned. The files are sorted by path.
+func findDatastoreFiles(ctx context.Context, ds *object.Datastore, directory string, glob string, recursive bool) ([]map[string]interface{}, error) {
+	b, err := ds.Browser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := types.HostDatastoreBrowserSearchSpec{
+		Details: &types.FileQueryFlags{
+			FileType:     true,
+			FileSize:     true,
+			Modification: true,
+		},
+	}
+	if glob != "" {
+		spec.MatchPattern = []string{glob}
+	}
+
+	var task *object.Task
+	if recursive {
+		task, err = b.SearchDatastoreSubFolders(ctx, ds.Path(directory), &spec)
+	} else {
+		task, err = b.SearchDatastore(ctx, ds.Path(directory), &spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := waitForTask(ctx, task, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []types.HostDatastoreBrowserSearchResults
+	switch r := info.Result.(type) {
+	case types.HostDatastoreBrowserSearchResults:
+		results = append(results, r)
+	case types.ArrayOfHostDatastoreBrowserSearchResults:
+		results = r.HostDatastoreBrowserSearchResults
+	}
+
+	files := []map[string]interface{}{}
+	for _, result := range results {
+		folder := normalizeDatastorePath(result.FolderPath)
+		for _, file := range result.File {
+			if _, ok := file.(*types.FolderFileInfo); ok {
+				continue
+			}
+			fi := file.GetFileInfo()
+			files = append(files, map[string]interface{}{
+				"name":          fi.Path,
+				"path":          path.Join(folder, fi.Path),
+				"size":          int(fi.FileSize),
+				"last_modified": fileModification(fi),
+			})
+		}
+	}
+
+	sort.Sort(datastoreFilesByPath(files))
+	return files, nil
+}
+
+type datastoreFilesByPath []map[string]interface{}
+
+func (s datastoreFilesByPath) Len() int      { return len(s) }
+func (s datastoreFilesByPath) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s datastoreFilesByPath) Less(i, j int) bool {
+	return s[i]["path"].(string) < s[j]["path"].(string)
+}
@@ -0,0 +1,66 @@
+package vsphere
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccVSphereDatastoreFiles_basic(t *testing.T) {
+	testVmdkFileData := []byte("# Disk DescriptorFile\n")
+	testVmdkFile := "/tmp/tf_test.vmdk"
+	err := ioutil.WriteFile(testVmdkFile, testVmdkFileData, 0644)
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDatastoreFilesConfig,
+					datacenter,
+					datastore,
+					testVmdkFile,
+					datacenter,
+					datastore,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.vsphere_datastore_files.listed", "files.#", "1"),
+					resource.TestCheckResourceAttr("data.vsphere_datastore_files.listed", "files.0.name", "tf_file_test.vmdk"),
+					resource.TestCheckResourceAttr("data.vsphere_datastore_files.listed", "files.0.path", "tf_files_test/sub/tf_file_test.vmdk"),
+					resource.TestCheckResourceAttr("data.vsphere_datastore_files.listed", "files.0.size", fmt.Sprintf("%d", len(testVmdkFileData))),
+				),
+			},
+		},
+	})
+	os.Remove(testVmdkFile)
+}
+
+const testAccCheckVSphereDatastoreFilesConfig = `
+resource "vsphere_file" "upload" {
+	datacenter = "%s"
+	datastore = "%s"
+	source_file = "%s"
+	destination_file = "tf_files_test/sub/tf_file_test.vmdk"
+	create_directories = true
+}
+
+data "vsphere_datastore_files" "listed" {
+	datacenter = "%s"
+	datastore = "%s"
+	path = "tf_files_test"
+	glob = "*.vmdk"
+	recursive = true
+	depends_on = ["vsphere_file.upload"]
+}
+`
@@ -0,0 +1,41 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccVSphereDatastore_basic(t *testing.T) {
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDatastoreConfig,
+					datacenter,
+					datastore,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.vsphere_datastore.ds", "capacity", regexp.MustCompile("^[1-9][0-9]*$")),
+					resource.TestMatchResourceAttr("data.vsphere_datastore.ds", "free_space", regexp.MustCompile("^[0-9]+$")),
+					resource.TestMatchResourceAttr("data.vsphere_datastore.ds", "type", regexp.MustCompile(".+")),
+				),
+			},
+		},
+	})
+}
+
+const testAccCheckVSphereDatastoreConfig = `
+data "vsphere_datastore" "ds" {
+	datacenter = "%s"
+	name = "%s"
+}
+`
@@ -0,0 +1,188 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereDatastores() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereDatastoresRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"host": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"cluster"},
+			},
+
+			"cluster": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"host"},
+			},
+
+			"filter": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"datastores": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"free_space": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+
+						"capacity": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceVSphereDatastoresRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	ctx := context.TODO()
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error finding datacenter: %s", err)
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+	collector := property.DefaultCollector(client.Client)
+
+	// The datastores of a host or cluster are properties of it, all others
+	// are found in the datacenter.
+	var refs []types.ManagedObjectReference
+	scope := dc.Reference().Value
+	if host, ok := d.GetOk("host"); ok {
+		h, err := finder.HostSystem(ctx, host.(string))
+		if err != nil {
+			return fmt.Errorf("error finding host %s: %s", host, err)
+		}
+		var mh mo.HostSystem
+		err = collector.RetrieveOne(ctx, h.Reference(), []string{"datastore"}, &mh)
+		if err != nil {
+			return fmt.Errorf("error reading datastores of host %s: %s", host, err)
+		}
+		refs = mh.Datastore
+		scope = h.Reference().Value
+	} else if cluster, ok := d.GetOk("cluster"); ok {
+		c, err := finder.ClusterComputeResource(ctx, cluster.(string))
+		if err != nil {
+			return fmt.Errorf("error finding cluster %s: %s", cluster, err)
+		}
+		var mc mo.ClusterComputeResource
+		err = collector.RetrieveOne(ctx, c.Reference(), []string{"datastore"}, &mc)
+		if err != nil {
+			return fmt.Errorf("error reading datastores of cluster %s: %s", cluster, err)
+		}
+		refs = mc.Datastore
+		scope = c.Reference().Value
+	} else {
+		// A datacenter without datastores is not an error
+		dss, err := finder.DatastoreList(ctx, "*")
+		if err != nil && !isDatastoreNotFoundError(err) {
+			return fmt.Errorf("error listing datastores: %s", err)
+		}
+		for _, ds := range dss {
+			refs = append(refs, ds.Reference())
+		}
+	}
+
+	log.Printf("[DEBUG] Reading %d datastores of %s", len(refs), scope)
+
+	// A single property collector call for all of them
+	var mdss []mo.Datastore
+	if len(refs) > 0 {
+		err = collector.Retrieve(ctx, refs, []string{"name", "summary"}, &mdss)
+		if err != nil {
+			return fmt.Errorf("error reading datastores: %s", err)
+		}
+	}
+
+	d.SetId(scope)
+	d.Set("datastores", datastoreList(mdss, interfacesToStrings(d.Get("filter").([]interface{}))))
+
+	return nil
+}
+
+// datastoreList returns the datastores whose type is one of filter, ignoring
+// case, or all of them without a filter. The datastores are sorted by name.
+func datastoreList(mdss []mo.Datastore, filter []string) []map[string]interface{} {
+	datastores := []map[string]interface{}{}
+	for _, mds := range mdss {
+		if len(filter) > 0 && !containsFold(filter, mds.Summary.Type) {
+			continue
+		}
+		datastores = append(datastores, map[string]interface{}{
+			"name":       mds.Name,
+			"id":         mds.Reference().Value,
+			"type":       mds.Summary.Type,
+			"free_space": int(mds.Summary.FreeSpace),
+			"capacity":   int(mds.Summary.Capacity),
+		})
+	}
+
+	sort.Sort(datastoresByName(datastores))
+	return datastores
+}
+
+// containsFold returns whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type datastoresByName []map[string]interface{}
+
+func (s datastoresByName) Len() int      { return len(s) }
+func (s datastoresByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s datastoresByName) Less(i, j int) bool {
+	return s[i]["name"].(string) < s[j]["name"].(string)
+}
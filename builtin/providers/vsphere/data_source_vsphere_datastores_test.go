@@ -0,0 +1,82 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Listing the datastores of a datacenter
+func TestAccVSphereDatastoresDataSource_basic(t *testing.T) {
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccCheckVSphereDatastoresDataSourceConfig, datacenter),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereDatastoresContains("data.vsphere_datastores.all", datastore),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVSphereDatastoresContains(n, name string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Resource not found: %s", n)
+		}
+
+		for k, v := range rs.Primary.Attributes {
+			if strings.HasSuffix(k, ".name") && v == name {
+				return nil
+			}
+		}
+		return fmt.Errorf("datastore %s not listed in %s", name, n)
+	}
+}
+
+const testAccCheckVSphereDatastoresDataSourceConfig = `
+data "vsphere_datastores" "all" {
+	datacenter = "%s"
+}
+`
+
+func TestDatastoreList(t *testing.T) {
+	datastore := func(id, name, dsType string, free, capacity int64) mo.Datastore {
+		var mds mo.Datastore
+		mds.Self = types.ManagedObjectReference{Type: "Datastore", Value: id}
+		mds.Name = name
+		mds.Summary = types.DatastoreSummary{Type: dsType, FreeSpace: free, Capacity: capacity}
+		return mds
+	}
+	mdss := []mo.Datastore{
+		datastore("datastore-3", "nfs-iso", "NFS", 100, 1000),
+		datastore("datastore-1", "local-ssd", "VMFS", 200, 400),
+		datastore("datastore-2", "vsan", "vsan", 300, 3000),
+	}
+
+	all := datastoreList(mdss, nil)
+	if len(all) != 3 || all[0]["name"] != "local-ssd" || all[2]["name"] != "vsan" {
+		t.Fatalf("expected all datastores sorted by name, got %v", all)
+	}
+	if all[0]["id"] != "datastore-1" || all[0]["free_space"] != 200 || all[0]["capacity"] != 400 {
+		t.Fatalf("unexpected datastore %v", all[0])
+	}
+
+	filtered := datastoreList(mdss, []string{"vmfs", "vSAN"})
+	if len(filtered) != 2 || filtered[0]["name"] != "local-ssd" || filtered[1]["name"] != "vsan" {
+		t.Fatalf("expected the VMFS and vSAN datastores, got %v", filtered)
+	}
+}
@@ -0,0 +1,125 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+// folderTypes are the types of inventory folders a datacenter has a root
+// folder for.
+var folderTypes = []string{"vm", "datastore", "network", "host"}
+
+func dataSourceVSphereFolder() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereFolderRead,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"type": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "vm",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					for _, t := range folderTypes {
+						if value == t {
+							return
+						}
+					}
+					errors = append(errors, fmt.Errorf(
+						"%q must be one of %s", k, strings.Join(folderTypes, ", ")))
+					return
+				},
+			},
+
+			"inventory_path": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceVSphereFolderRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	p := d.Get("path").(string)
+	folderType := d.Get("type").(string)
+
+	dc, err := getDatacenter(client, d.Get("datacenter").(string))
+	if err != nil {
+		return fmt.Errorf("error %s", err)
+	}
+
+	log.Printf("[DEBUG] Reading %s folder: %s", folderType, p)
+
+	folder, err := findTypedFolder(context.TODO(), client, dc, folderType, p)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(folder.Reference().Value)
+	d.Set("inventory_path", folder.InventoryPath)
+
+	return nil
+}
+
+// findTypedFolder finds the folder p relative to the root folder of
+// folderType in dc, e.g. the datastore folder "iso" at "/dc1/datastore/iso".
+// An empty p is the root folder itself.
+func findTypedFolder(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, folderType, p string) (*object.Folder, error) {
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading folders of datacenter: %s", err)
+	}
+
+	root, err := rootFolder(folders, folderType)
+	if err != nil {
+		return nil, err
+	}
+
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return root, nil
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	folder, err := finder.Folder(ctx, inventoryPattern(root.InventoryPath+"/"+p))
+	if err != nil {
+		return nil, fmt.Errorf("error finding %s folder %s: %s", folderType, p, err)
+	}
+	return folder, nil
+}
+
+// rootFolder returns the root folder of folderType among the folders of a
+// datacenter.
+func rootFolder(folders *object.DatacenterFolders, folderType string) (*object.Folder, error) {
+	switch folderType {
+	case "vm":
+		return folders.VmFolder, nil
+	case "datastore":
+		return folders.DatastoreFolder, nil
+	case "network":
+		return folders.NetworkFolder, nil
+	case "host":
+		return folders.HostFolder, nil
+	}
+	return nil, fmt.Errorf("unknown folder type %q", folderType)
+}
@@ -0,0 +1,63 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/vmware/govmomi/object"
+)
+
+func TestAccVSphereFolderDataSource_datastoreRoot(t *testing.T) {
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereFolderDataSourceConfig,
+					datacenter,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.vsphere_folder.datastore", "id", regexp.MustCompile("^group-")),
+					resource.TestMatchResourceAttr("data.vsphere_folder.datastore", "inventory_path", regexp.MustCompile("/datastore$")),
+				),
+			},
+		},
+	})
+}
+
+const testAccCheckVSphereFolderDataSourceConfig = `
+data "vsphere_folder" "datastore" {
+	datacenter = "%s"
+	path = "/"
+	type = "datastore"
+}
+`
+
+func TestRootFolder(t *testing.T) {
+	folders := &object.DatacenterFolders{
+		VmFolder:        &object.Folder{InventoryPath: "/dc1/vm"},
+		HostFolder:      &object.Folder{InventoryPath: "/dc1/host"},
+		DatastoreFolder: &object.Folder{InventoryPath: "/dc1/datastore"},
+		NetworkFolder:   &object.Folder{InventoryPath: "/dc1/network"},
+	}
+
+	for _, folderType := range folderTypes {
+		folder, err := rootFolder(folders, folderType)
+		if err != nil {
+			t.Fatalf("%s: %s", folderType, err)
+		}
+		if expected := "/dc1/" + folderType; folder.InventoryPath != expected {
+			t.Errorf("%s: expected %s, got %s", folderType, expected, folder.InventoryPath)
+		}
+	}
+
+	if _, err := rootFolder(folders, "storage"); err == nil {
+		t.Fatal("expected an error for an unknown folder type")
+	}
+}
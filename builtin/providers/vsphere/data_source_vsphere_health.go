@@ -0,0 +1,81 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25/methods"
+	"golang.org/x/net/context"
+)
+
+func dataSourceVSphereHealth() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVSphereHealthRead,
+
+		Schema: map[string]*schema.Schema{
+			"api_version": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"vcenter_build": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"product": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"user_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"server_time": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceVSphereHealthRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*govmomi.Client)
+	ctx := context.TODO()
+	server := client.URL().Host
+
+	log.Printf("[DEBUG] Checking session with %s", server)
+
+	session, err := client.SessionManager.UserSession(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking session with %s: %s", server, err)
+	}
+	if session == nil {
+		return fmt.Errorf("the session with %s is not authenticated, check user and password of the provider", server)
+	}
+
+	now, err := methods.GetCurrentTime(ctx, client)
+	if err != nil {
+		return fmt.Errorf("error reading time of %s: %s", server, err)
+	}
+
+	about := client.ServiceContent.About
+	id := about.InstanceUuid
+	if id == "" {
+		id = server
+	}
+
+	d.SetId(id)
+	d.Set("api_version", about.ApiVersion)
+	d.Set("vcenter_build", about.Build)
+	d.Set("product", about.FullName)
+	d.Set("user_name", session.UserName)
+	d.Set("server_time", now.UTC().Format(time.RFC3339))
+
+	return nil
+}
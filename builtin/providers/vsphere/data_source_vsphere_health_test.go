@@ -0,0 +1,30 @@
+package vsphere
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccVSphereHealth_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckVSphereHealthConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.vsphere_health.vcenter", "api_version", regexp.MustCompile(`^\d+\.\d+`)),
+					resource.TestMatchResourceAttr("data.vsphere_health.vcenter", "vcenter_build", regexp.MustCompile(`^\d+$`)),
+					resource.TestMatchResourceAttr("data.vsphere_health.vcenter", "user_name", regexp.MustCompile(".+")),
+					resource.TestMatchResourceAttr("data.vsphere_health.vcenter", "server_time", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T`)),
+				),
+			},
+		},
+	})
+}
+
+const testAccCheckVSphereHealthConfig = `
+data "vsphere_health" "vcenter" {}
+`
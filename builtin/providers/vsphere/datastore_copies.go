@@ -0,0 +1,174 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/task"
+	"golang.org/x/net/context"
+)
+
+// fileCopy returns f for its copy on the datastore named datastore. The file
+// is only attached to a vm, and its directories are only tracked, on its own
+// datastore.
+func fileCopy(f *file, datastore string) *file {
+	c := *f
+	c.datastore = datastore
+	c.copies = nil
+	c.copiesModified = nil
+	c.attachToVM = nil
+	c.createdDirectories = nil
+	// Copies have the name the file was uploaded to
+	if c.onConflict == "rename" {
+		c.onConflict = "fail"
+	}
+	// Copies are uploaded at other times, so isReplacedFile must not compare
+	// them against the modification time of the file itself, but against
+	// their own, if it was recorded.
+	c.lastModified = ""
+	for i, v := range f.copies {
+		if v == datastore && i < len(f.copiesModified) {
+			c.lastModified = f.copiesModified[i]
+		}
+	}
+	return &c
+}
+
+// uploadCopies uploads f to the datastores of its copies, once it has been
+// uploaded to its own. It returns the datastore members of the copies that
+// were uploaded, also when a later one fails, so that they are deleted with
+// the resource. Their modification times are kept in f.copiesModified, in
+// the same order.
+func uploadCopies(ctx context.Context, client *govmomi.Client, f *file) ([]string, error) {
+	var members []string
+	f.copiesModified = nil
+	for _, datastore := range f.copies {
+		c := fileCopy(f, datastore)
+		err := createFile(ctx, client, c)
+		if err != nil {
+			return members, fmt.Errorf("error uploading copy to datastore %q, %d of %d copies were uploaded: %s",
+				datastore, len(members), len(f.copies), err)
+		}
+		log.Printf("[INFO] uploaded copy %s", c.datastoreMember)
+		members = append(members, c.datastoreMember)
+		f.copiesModified = append(f.copiesModified, c.lastModified)
+	}
+	return members, nil
+}
+
+// missingCopy returns the datastore of the first copy of f that is gone, has
+// a size other than size, or was modified after the time recorded for it,
+// or an empty string if all copies are intact. It also returns the
+// modification times of the copies, in the order of f.copies.
+func missingCopy(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, f *file, size int64) (string, []string, error) {
+	var modified []string
+	for i, datastore := range f.copies {
+		ds, err := lookupDatastoreContext(ctx, client, dc, datastore)
+		if err != nil {
+			return "", nil, fmt.Errorf("error finding datastore %q of copy: %s", datastore, err)
+		}
+
+		b, err := datastoreBrowser(ctx, client, dc, ds, f.host)
+		if err != nil {
+			return "", nil, err
+		}
+
+		info, err := statDatastoreFile(ctx, ds, b, f.destinationFile)
+		if err != nil {
+			if isFileNotFoundError(err) {
+				log.Printf("[INFO] copy %s is gone", ds.Path(f.destinationFile))
+				return datastore, nil, nil
+			}
+			return "", nil, err
+		}
+
+		fi := info.GetFileInfo()
+		if fi.FileSize != size {
+			log.Printf("[INFO] copy %s changed size (%d bytes, was %d)", ds.Path(f.destinationFile), fi.FileSize, size)
+			return datastore, nil, nil
+		}
+
+		m := fileModification(fi)
+		if i < len(f.copiesModified) && f.copiesModified[i] != "" && m != "" && m != f.copiesModified[i] {
+			log.Printf("[INFO] copy %s was modified at %s (was %s)", ds.Path(f.destinationFile), m, f.copiesModified[i])
+			return datastore, nil, nil
+		}
+		modified = append(modified, m)
+	}
+	return "", modified, nil
+}
+
+// moveCopies moves the copies of f from oldPath in oldDc to newPath in newDc.
+// Copies that are already at newPath, because an earlier update failed after
+// moving them, are skipped.
+func moveCopies(ctx context.Context, client *govmomi.Client, oldDc, newDc *object.Datacenter, f *file, oldPath, newPath string) error {
+	fm := object.NewFileManager(client.Client)
+	for _, datastore := range f.copies {
+		oldDs, err := lookupDatastoreContext(ctx, client, oldDc, datastore)
+		if err != nil {
+			return fmt.Errorf("error finding datastore %q of copy: %s", datastore, err)
+		}
+		newDs, err := lookupDatastoreContext(ctx, client, newDc, datastore)
+		if err != nil {
+			return fmt.Errorf("error finding datastore %q of copy: %s", datastore, err)
+		}
+
+		if renamedAlready(ctx, oldDs, oldPath, newPath) {
+			log.Printf("[INFO] copy %s was already moved to %s", oldDs.Path(oldPath), newDs.Path(newPath))
+			continue
+		}
+
+		if f.createDirectories {
+			_, err = createDirectory(ctx, fm, newDs, newDc, newPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		moveTask, err := fm.MoveDatastoreFile(ctx, oldDs.Path(oldPath), oldDc, newDs.Path(newPath), newDc, f.force)
+		if err != nil {
+			return fmt.Errorf("error moving copy %s: %s", oldDs.Path(oldPath), err)
+		}
+		log.Printf("[INFO] moving copy %s to %s in task %s", oldDs.Path(oldPath), newDs.Path(newPath), moveTask.Reference().Value)
+
+		_, err = waitForTask(ctx, moveTask, nil)
+		if err != nil {
+			if terr, ok := err.(task.Error); ok {
+				err = fmt.Errorf("%s", terr.LocalizedMessage)
+			}
+			return fmt.Errorf("moving copy %s failed: %s", oldDs.Path(oldPath), err)
+		}
+	}
+	return nil
+}
+
+// deleteCopies deletes the copies of f. Copies that are gone already are
+// skipped, so a failed delete can be tried again.
+func deleteCopies(ctx context.Context, client *govmomi.Client, f *file) error {
+	for _, datastore := range f.copies {
+		err := deleteFile(ctx, client, fileCopy(f, datastore))
+		if isFileNotFoundError(err) {
+			log.Printf("[DEBUG] copy on datastore %s is gone already", datastore)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting copy on datastore %q: %s", datastore, err)
+		}
+	}
+	return nil
+}
+
+// duplicateDatastore returns the first name that is listed more than once in
+// datastores, or an empty string if there is none.
+func duplicateDatastore(datastores []string) string {
+	seen := make(map[string]bool)
+	for _, datastore := range datastores {
+		if seen[datastore] {
+			return datastore
+		}
+		seen[datastore] = true
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package vsphere
+
+import (
+	"testing"
+)
+
+func TestDuplicateDatastore(t *testing.T) {
+	cases := []struct {
+		datastores []string
+		duplicate  string
+	}{
+		{nil, ""},
+		{[]string{"datastore1"}, ""},
+		{[]string{"datastore1", "datastore2"}, ""},
+		{[]string{"datastore1", "datastore2", "datastore1"}, "datastore1"},
+	}
+
+	for _, c := range cases {
+		if duplicate := duplicateDatastore(c.datastores); duplicate != c.duplicate {
+			t.Errorf("%v: expected %q, got %q", c.datastores, c.duplicate, duplicate)
+		}
+	}
+}
+
+func TestFileCopy(t *testing.T) {
+	f := &file{
+		datastore:          "datastore1",
+		copies:             []string{"datastore2"},
+		destinationFile:    "iso/ubuntu.iso",
+		attachToVM:         &fileAttachment{vm: "vm1"},
+		createdDirectories: []string{"iso"},
+		lastModified:       "2017-01-01T00:00:00Z",
+	}
+
+	c := fileCopy(f, "datastore2")
+	if c.datastore != "datastore2" || c.destinationFile != f.destinationFile {
+		t.Fatalf("expected iso/ubuntu.iso on datastore2, got %s on %s", c.destinationFile, c.datastore)
+	}
+	if c.copies != nil || c.attachToVM != nil || c.createdDirectories != nil || c.lastModified != "" {
+		t.Fatalf("copy kept settings of the file itself: %+v", c)
+	}
+	if f.datastore != "datastore1" || f.attachToVM == nil {
+		t.Fatalf("file itself was changed: %+v", f)
+	}
+
+	// A copy is compared against its own modification time
+	f.copies = []string{"datastore2", "datastore3"}
+	f.copiesModified = []string{"2017-01-02T00:00:00Z"}
+	if c := fileCopy(f, "datastore2"); c.lastModified != "2017-01-02T00:00:00Z" || c.copiesModified != nil {
+		t.Fatalf("expected the modification time of the copy, got %+v", c)
+	}
+	if c := fileCopy(f, "datastore3"); c.lastModified != "" {
+		t.Fatalf("expected no modification time for a copy without one, got %q", c.lastModified)
+	}
+}
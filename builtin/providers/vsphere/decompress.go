@@ -0,0 +1,103 @@
+package vsphere
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// decompressExtensions maps the values of decompress, other than none, to
+// the extension of files compressed that way.
+var decompressExtensions = map[string]string{
+	"gzip":  ".gz",
+	"bzip2": ".bz2",
+}
+
+// decompressedFile is a local compressed file, read decompressed.
+type decompressedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (d *decompressedFile) Close() error {
+	return d.file.Close()
+}
+
+// openDecompressed opens the local file at path for reading its content
+// decompressed with format.
+func openDecompressed(path, format string) (io.ReadCloser, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	switch format {
+	case "gzip":
+		r, err = gzip.NewReader(fh)
+		if err != nil {
+			fh.Close()
+			return nil, fmt.Errorf("%s is not a valid gzip file: %s", path, err)
+		}
+	case "bzip2":
+		r = bzip2.NewReader(fh)
+	default:
+		fh.Close()
+		return nil, fmt.Errorf("unsupported decompress %q", format)
+	}
+
+	return &decompressedFile{Reader: r, file: fh}, nil
+}
+
+// scanDecompressed reads the local file at path decompressed with format,
+// and returns the size and hex encoded digest of the decompressed content.
+// Corrupt or truncated input is reported as an error, which makes this a
+// check of the whole file before any of it is uploaded.
+func scanDecompressed(path, format, checksumType string) (int64, string, error) {
+	h, err := newChecksumHash(checksumType)
+	if err != nil {
+		return 0, "", err
+	}
+
+	r, err := openDecompressed(path, format)
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return 0, "", fmt.Errorf("error decompressing %s with %s: %s", path, format, err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// trimCompressionExtension removes the extension of format from path, e.g.
+// ubuntu.iso.gz becomes ubuntu.iso for gzip.
+func trimCompressionExtension(path, format string) string {
+	ext, ok := decompressExtensions[format]
+	if !ok || len(path) < len(ext) || !strings.EqualFold(path[len(path)-len(ext):], ext) {
+		return path
+	}
+	return path[:len(path)-len(ext)]
+}
+
+// verifyDecompressedFileFormat is verifyFileFormat for the decompressed
+// content of a local file.
+func verifyDecompressedFileFormat(path, decompress, format string) error {
+	if format == "" {
+		return nil
+	}
+
+	r, err := openDecompressed(path, decompress)
+	if err != nil {
+		return fmt.Errorf("error reading %s to check its format: %s", path, err)
+	}
+	defer r.Close()
+
+	return checkFileFormat(r, path, format)
+}
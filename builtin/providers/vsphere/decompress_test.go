@@ -0,0 +1,80 @@
+package vsphere
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// hostsBzip2 is "hostname=terraform\n" compressed with bzip2
+const hostsBzip2 = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x19\x7e\x76\x60\x00\x00\x03\xc9\x80\x00\x10\x00\x02\x23\x43\x9c\x00\x20\x00\x22\x03\x41\xa0\x40\xd0\x34\x28\x1c\x8e\xba\x97\x8c\x23\x4b\x6f\x78\xbb\x92\x29\xc2\x84\x80\xcb\xf3\xb3\x00"
+
+func TestScanDecompressed(t *testing.T) {
+	const expected = "373d3d2ff9f91c29ffcb01ccb719412a4534261fd4431e52aff39af265b1e066"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("hostname=terraform\n"))
+	w.Close()
+
+	cases := []struct {
+		format  string
+		content []byte
+		valid   bool
+	}{
+		{"gzip", gz.Bytes(), true},
+		{"bzip2", []byte(hostsBzip2), true},
+		// Truncated input
+		{"gzip", gz.Bytes()[:gz.Len()-4], false},
+		{"bzip2", []byte(hostsBzip2[:30]), false},
+		// Not compressed at all
+		{"gzip", []byte("hostname=terraform\n"), false},
+		{"bzip2", []byte("hostname=terraform\n"), false},
+	}
+
+	for i, c := range cases {
+		f, err := ioutil.TempFile("", "tf-vsphere-decompress")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(c.content)
+		f.Close()
+
+		size, checksum, err := scanDecompressed(f.Name(), c.format, "sha256")
+		os.Remove(f.Name())
+
+		if !c.valid {
+			if err == nil {
+				t.Errorf("%d: expected an error for corrupt %s input", i, c.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: error %s", i, err)
+			continue
+		}
+		if size != 19 || checksum != expected {
+			t.Errorf("%d: expected 19 bytes with checksum %s, got %d bytes with %s", i, expected, size, checksum)
+		}
+	}
+}
+
+func TestTrimCompressionExtension(t *testing.T) {
+	cases := []struct {
+		path, format, expected string
+	}{
+		{"/tmp/ubuntu.iso.gz", "gzip", "/tmp/ubuntu.iso"},
+		{"/tmp/UBUNTU.ISO.GZ", "gzip", "/tmp/UBUNTU.ISO"},
+		{"/tmp/ubuntu.iso.bz2", "bzip2", "/tmp/ubuntu.iso"},
+		{"/tmp/ubuntu.iso.gz", "bzip2", "/tmp/ubuntu.iso.gz"},
+		{"/tmp/ubuntu.iso", "none", "/tmp/ubuntu.iso"},
+	}
+
+	for _, c := range cases {
+		if actual := trimCompressionExtension(c.path, c.format); actual != c.expected {
+			t.Errorf("%s with %s: expected %s, got %s", c.path, c.format, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package vsphere
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// fileFormatHeaderSize is how much of a file is read to detect its format. It
+// has to cover the ISO 9660 signature, which follows 16 reserved sectors.
+const fileFormatHeaderSize = 0x8001 + 5
+
+// fileFormats maps the values of expected_format to a check of the first
+// bytes of a file.
+var fileFormats = map[string]func(header []byte) bool{
+	// ISO 9660 and UDF bridge images carry CD001 in the first volume
+	// descriptor, at sector 16.
+	"iso": func(header []byte) bool {
+		return hasMagicAt(header, 0x8001, "CD001")
+	},
+
+	// Sparse and stream-optimized extents start with KDMV, ESX sparse
+	// extents with COWD. Flat disks come with a text descriptor file.
+	"vmdk": func(header []byte) bool {
+		return hasMagicAt(header, 0, "KDMV") ||
+			hasMagicAt(header, 0, "COWD") ||
+			bytes.HasPrefix(header, []byte("# Disk DescriptorFile"))
+	},
+
+	// An OVF descriptor is an XML document with an Envelope root element.
+	"ovf": func(header []byte) bool {
+		header = bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))
+		header = bytes.TrimSpace(header)
+		return bytes.HasPrefix(header, []byte("<")) && bytes.Contains(header, []byte("Envelope"))
+	},
+
+	// An OVA is a tar archive.
+	"ova": func(header []byte) bool {
+		return hasMagicAt(header, 257, "ustar")
+	},
+}
+
+func hasMagicAt(header []byte, offset int, magic string) bool {
+	return len(header) >= offset+len(magic) && string(header[offset:offset+len(magic)]) == magic
+}
+
+// supportedFileFormats returns the values accepted by expected_format.
+func supportedFileFormats() []string {
+	var formats []string
+	for format := range fileFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// verifyFileFormat checks the magic bytes of a local file against
+// expected_format, so a corrupt or mistaken image is not uploaded. An empty
+// format skips the check.
+func verifyFileFormat(path string, format string) error {
+	if format == "" {
+		return nil
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s to check its format: %s", path, err)
+	}
+	defer fh.Close()
+
+	return checkFileFormat(fh, path, format)
+}
+
+// checkFileFormat checks the first bytes read from r, the content of the
+// file name, against format.
+func checkFileFormat(r io.Reader, name string, format string) error {
+	check, ok := fileFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported expected_format %q", format)
+	}
+
+	header := make([]byte, fileFormatHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("error reading %s to check its format: %s", name, err)
+	}
+
+	if !check(header[:n]) {
+		return fmt.Errorf("%s is not a valid %s file", name, format)
+	}
+	return nil
+}
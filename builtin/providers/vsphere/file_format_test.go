@@ -0,0 +1,57 @@
+package vsphere
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVerifyFileFormat(t *testing.T) {
+	iso := make([]byte, 0x9000)
+	copy(iso[0x8001:], "CD001")
+	ova := make([]byte, 1024)
+	copy(ova[257:], "ustar")
+
+	cases := []struct {
+		format  string
+		content []byte
+		valid   bool
+	}{
+		{"", []byte("anything"), true},
+		{"iso", iso, true},
+		{"iso", make([]byte, 0x9000), false},
+		{"iso", []byte("CD001"), false},
+		{"vmdk", []byte("KDMV\x01\x00\x00\x00"), true},
+		{"vmdk", []byte("# Disk DescriptorFile\nversion=1\n"), true},
+		{"vmdk", iso, false},
+		{"ovf", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<ovf:Envelope xmlns:ovf=\"x\">"), true},
+		{"ovf", []byte("<html><body>Not Found</body></html>"), false},
+		{"ova", ova, true},
+		{"ova", []byte{}, false},
+	}
+
+	for _, tc := range cases {
+		fh, err := ioutil.TempFile("", "tf_test_format")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(fh.Name())
+		fh.Write(tc.content)
+		fh.Close()
+
+		err = verifyFileFormat(fh.Name(), tc.format)
+		if tc.valid && err != nil {
+			t.Errorf("expected %q content %q to be valid, got: %s", tc.format, truncate(tc.content), err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected %q content %q to be invalid", tc.format, truncate(tc.content))
+		}
+	}
+}
+
+func truncate(b []byte) []byte {
+	if len(b) > 16 {
+		return b[:16]
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+const (
+	// guestVerifyPollInterval is the delay between the first checks of
+	// guest_verify. It doubles up to guestVerifyMaxPollInterval.
+	guestVerifyPollInterval    = 2 * time.Second
+	guestVerifyMaxPollInterval = 30 * time.Second
+)
+
+// guestVerify configures the guest_verify check of an uploaded file: a file
+// must appear inside the guest file system of a virtual machine, e.g. once
+// an agent in the guest picked up the upload.
+type guestVerify struct {
+	vm       string
+	user     string
+	password string
+	path     string
+	timeout  time.Duration
+}
+
+// expandGuestVerify reads a guest_verify block, returning nil if there is
+// none.
+func expandGuestVerify(v interface{}) *guestVerify {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+
+	// Validated by the schema
+	timeout, _ := time.ParseDuration(m["timeout"].(string))
+
+	return &guestVerify{
+		vm:       m["vm"].(string),
+		user:     m["user"].(string),
+		password: m["password"].(string),
+		path:     m["path"].(string),
+		timeout:  timeout,
+	}
+}
+
+// verifyGuestFile polls the guest operations of the virtual machine of g
+// until its file exists in the guest. The guest not being ready, such as
+// VMware Tools not running yet, is waited out like a missing file; invalid
+// guest credentials fail right away.
+func verifyGuestFile(ctx context.Context, client *govmomi.Client, datacenter string, g *guestVerify) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	dc, err := getDatacenterContext(ctx, client, datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", datacenter, err)
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	vm, err := finder.VirtualMachine(ctx, g.vm)
+	if err != nil {
+		return fmt.Errorf("error finding vm %s: %s", g.vm, err)
+	}
+
+	if client.ServiceContent.GuestOperationsManager == nil {
+		return fmt.Errorf("guest operations are not supported by %s", client.URL().Host)
+	}
+	var gom mo.GuestOperationsManager
+	collector := property.DefaultCollector(client.Client)
+	err = collector.RetrieveOne(ctx, *client.ServiceContent.GuestOperationsManager, []string{"fileManager"}, &gom)
+	if err != nil {
+		return fmt.Errorf("error reading guest operations manager: %s", err)
+	}
+
+	req := types.ListFilesInGuest{
+		This: *gom.FileManager,
+		Vm:   vm.Reference(),
+		Auth: &types.NamePasswordAuthentication{
+			Username: g.user,
+			Password: g.password,
+		},
+		FilePath: g.path,
+	}
+
+	interval := guestVerifyPollInterval
+	for {
+		_, err := methods.ListFilesInGuest(ctx, client.Client, &req)
+		if err == nil {
+			log.Printf("[INFO] %s exists in the guest of vm %s", g.path, g.vm)
+			return nil
+		}
+		if !isGuestNotReadyError(err) {
+			return fmt.Errorf("error checking %s in the guest of vm %s: %s", g.path, g.vm, err)
+		}
+
+		log.Printf("[DEBUG] %s not found in the guest of vm %s yet, checking again in %s: %s", g.path, g.vm, interval, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not appear in the guest of vm %s within %s: %s", g.path, g.vm, g.timeout, err)
+		case <-time.After(interval):
+		}
+		interval = nextPollInterval(interval, guestVerifyMaxPollInterval)
+	}
+}
+
+// isGuestNotReadyError returns whether err means that the guest file doesn't
+// exist yet, or that the guest can't be asked yet, as opposed to a failure
+// that waiting won't fix.
+func isGuestNotReadyError(err error) bool {
+	if err == nil || !soap.IsSoapFault(err) {
+		return false
+	}
+
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.FileNotFound, *types.FileNotFound,
+		types.GuestOperationsUnavailable, *types.GuestOperationsUnavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package vsphere
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestExpandGuestVerify(t *testing.T) {
+	if g := expandGuestVerify([]interface{}{}); g != nil {
+		t.Fatalf("expected no guest check, got %#v", g)
+	}
+
+	g := expandGuestVerify([]interface{}{
+		map[string]interface{}{
+			"vm":       "web-1",
+			"user":     "root",
+			"password": "secret",
+			"path":     "/var/lib/agent/config.iso",
+			"timeout":  "2m",
+		},
+	})
+	if g.vm != "web-1" || g.user != "root" || g.path != "/var/lib/agent/config.iso" {
+		t.Fatalf("unexpected guest check %#v", g)
+	}
+	if g.timeout != 2*time.Minute {
+		t.Fatalf("expected a timeout of 2m, got %s", g.timeout)
+	}
+}
+
+func TestIsGuestNotReadyError(t *testing.T) {
+	fault := func(f types.AnyType) error {
+		sf := &soap.Fault{Code: "ServerFaultCode"}
+		sf.Detail.Fault = f
+		return soap.WrapSoapFault(sf)
+	}
+
+	if !isGuestNotReadyError(fault(types.FileNotFound{})) {
+		t.Fatal("expected a missing guest file to be waited for")
+	}
+	if !isGuestNotReadyError(fault(types.GuestOperationsUnavailable{})) {
+		t.Fatal("expected unavailable guest operations to be waited for")
+	}
+	if isGuestNotReadyError(fault(types.InvalidGuestLogin{})) {
+		t.Fatal("expected invalid guest credentials to fail right away")
+	}
+	if isGuestNotReadyError(errors.New("connection refused")) {
+		t.Fatal("expected other errors to fail right away")
+	}
+}
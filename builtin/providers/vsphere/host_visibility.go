@@ -0,0 +1,101 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+const (
+	// hostVisibilityPollInterval is the delay between the first checks of
+	// wait_for_host_visibility. It doubles up to
+	// hostVisibilityMaxPollInterval.
+	hostVisibilityPollInterval    = 2 * time.Second
+	hostVisibilityMaxPollInterval = 30 * time.Second
+)
+
+// waitForHostVisibility waits until the uploaded file f is seen by the
+// datastore browser of every connected host that mounts its datastore, so a
+// vm on any of them can use it right away.
+func waitForHostVisibility(ctx context.Context, client *govmomi.Client, f *file, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dc, err := getDatacenterContext(ctx, client, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", f.datacenter, err)
+	}
+
+	ds, err := lookupDatastoreContext(ctx, client, dc, f.datastoreMember)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", f.datastoreMember, err)
+	}
+
+	attached, err := ds.AttachedHosts(ctx)
+	if err != nil {
+		return fmt.Errorf("error finding hosts of datastore %s: %s", ds.Name(), err)
+	}
+	if len(attached) == 0 {
+		return nil
+	}
+
+	refs := make([]types.ManagedObjectReference, len(attached))
+	for i, h := range attached {
+		refs[i] = h.Reference()
+	}
+	var hosts []mo.HostSystem
+	collector := property.DefaultCollector(client.Client)
+	err = collector.Retrieve(ctx, refs, []string{"name", "datastoreBrowser"}, &hosts)
+	if err != nil {
+		return fmt.Errorf("error reading hosts of datastore %s: %s", ds.Name(), err)
+	}
+
+	p := ds.Path(f.destinationFile)
+	start := time.Now()
+	interval := hostVisibilityPollInterval
+	for {
+		var pending []mo.HostSystem
+		for _, h := range hosts {
+			b := object.NewHostDatastoreBrowser(client.Client, h.DatastoreBrowser)
+			_, err := statDatastoreFile(ctx, ds, b, f.destinationFile)
+			if err == nil {
+				log.Printf("[DEBUG] %s is visible on host %s after %s", p, h.Name, time.Since(start))
+				continue
+			}
+			if ctx.Err() == nil && !isFileNotFoundError(err) {
+				return fmt.Errorf("error checking %s on host %s: %s", p, h.Name, err)
+			}
+			pending = append(pending, h)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		hosts = pending
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s is not visible on hosts %s after %s", p, strings.Join(hostNames(hosts), ", "), timeout)
+		case <-time.After(interval):
+		}
+		interval = nextPollInterval(interval, hostVisibilityMaxPollInterval)
+	}
+}
+
+// hostNames returns the sorted names of hosts.
+func hostNames(hosts []mo.HostSystem) []string {
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+	sort.Strings(names)
+	return names
+}
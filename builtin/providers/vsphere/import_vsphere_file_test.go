@@ -0,0 +1,187 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccVSphereFile_importBasic(t *testing.T) {
+	testVmdkFileData := []byte("# Disk DescriptorFile\n")
+	testVmdkFile, cleanup := testSeedSourceFile(t, "tf_test.vmdk", testVmdkFileData)
+	defer cleanup()
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	testMethod := "import"
+	resourceName := "vsphere_file." + testMethod
+	destinationFile := "tf_file_test.vmdk"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVSphereFileDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereFileConfig,
+					testMethod,
+					datacenter,
+					datastore,
+					testVmdkFile,
+					destinationFile,
+				),
+			},
+
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+				// These describe the local source and the upload, which an
+				// import can't know.
+				ImportStateVerifyIgnore: []string{
+					"source_file", "source_checksum", "source_used", "source_file_hash", "source_file_mtime",
+					"created_directories", "bytes_transferred", "upload_duration_seconds"},
+			},
+
+			{
+				ResourceName:     resourceName,
+				ImportState:      true,
+				ImportStateCheck: testAccCheckVSphereFileImportPlan(datacenter, datastore, testVmdkFile, destinationFile),
+			},
+		},
+	})
+}
+
+// testAccCheckVSphereFileImportPlan checks that the configuration of the
+// import test leaves the imported file as it is.
+func testAccCheckVSphereFileImportPlan(datacenter, datastore, sourceFile, destinationFile string) resource.ImportStateCheckFunc {
+	return func(states []*terraform.InstanceState) error {
+		if len(states) != 1 {
+			return fmt.Errorf("expected 1 imported file, got %d", len(states))
+		}
+		return checkImportedFilePlan(states[0], map[string]interface{}{
+			"datacenter":       datacenter,
+			"datastore":        datastore,
+			"source_file":      sourceFile,
+			"destination_file": destinationFile,
+		})
+	}
+}
+
+// checkImportedFilePlan returns an error if applying raw to the imported
+// state s would change anything but source_file, which uploads the file again
+// as documented.
+func checkImportedFilePlan(s *terraform.InstanceState, raw map[string]interface{}) error {
+	c, err := config.NewRawConfig(raw)
+	if err != nil {
+		return err
+	}
+
+	diff, err := resourceVSphereFile().Diff(s, terraform.NewResourceConfig(c))
+	if err != nil {
+		return err
+	}
+	if diff == nil {
+		return nil
+	}
+	for k, attr := range diff.Attributes {
+		if attr.RequiresNew {
+			return fmt.Errorf("importing replaces the file: %s changes from %q to %q", k, attr.Old, attr.New)
+		}
+		if k != "source_file" {
+			return fmt.Errorf("importing leaves a change: %s changes from %q to %q", k, attr.Old, attr.New)
+		}
+	}
+	return nil
+}
+
+func TestImportedFilePlan(t *testing.T) {
+	d := resourceVSphereFile().Data(&terraform.InstanceState{ID: "[datastore1] dc1/iso/ubuntu.iso"})
+	setImportedFile(d, "dc1", "datastore1", "iso/ubuntu.iso")
+
+	err := checkImportedFilePlan(d.State(), map[string]interface{}{
+		"datacenter":       "dc1",
+		"datastore":        "datastore1",
+		"source_file":      "/tmp/ubuntu.iso",
+		"destination_file": "iso/ubuntu.iso",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFileID(t *testing.T) {
+	cases := []struct {
+		id         string
+		datastore  string
+		datacenter string
+		path       string
+		err        bool
+	}{
+		{"[datastore1] dc1/iso/ubuntu.iso", "datastore1", "dc1", "iso/ubuntu.iso", false},
+		{"[datastore1] dc1//iso/ubuntu.iso", "datastore1", "dc1", "/iso/ubuntu.iso", false},
+		{"[datastore1] /iso/ubuntu.iso", "datastore1", "", "iso/ubuntu.iso", false},
+		{"[datastore1] ubuntu.iso", "datastore1", "", "ubuntu.iso", false},
+		{"[my datastore] dc1/ubuntu.iso", "my datastore", "dc1", "ubuntu.iso", false},
+		{"[ISO Store (Prod)] dc1/iso/ubuntu.iso", "ISO Store (Prod)", "dc1", "iso/ubuntu.iso", false},
+		{"datastore1/ubuntu.iso", "", "", "", true},
+		{"[] dc1/ubuntu.iso", "", "", "", true},
+		{"[datastore1] dc1/", "", "", "", true},
+	}
+
+	for _, tc := range cases {
+		datastore, datacenter, path, err := parseFileID(tc.id)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("%s: expected error", tc.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: error %s", tc.id, err)
+		}
+		if datastore != tc.datastore || datacenter != tc.datacenter || path != tc.path {
+			t.Fatalf("%s: expected (%q, %q, %q), got (%q, %q, %q)", tc.id,
+				tc.datastore, tc.datacenter, tc.path, datastore, datacenter, path)
+		}
+	}
+}
+
+func TestDatastoreObjectID(t *testing.T) {
+	for _, datastore := range []string{"datastore1", "ISO Store (Prod)", "ISO [Prod]"} {
+		id := datastoreObjectID(datastore, "dc1", "iso/ubuntu 16.04.iso")
+		ds, dc, p, err := parseFileID(id)
+		if err != nil {
+			t.Fatalf("%s: error %s", id, err)
+		}
+		if ds != datastore || dc != "dc1" || p != "iso/ubuntu 16.04.iso" {
+			t.Fatalf("%s: expected (%q, \"dc1\", \"iso/ubuntu 16.04.iso\"), got (%q, %q, %q)", id, datastore, ds, dc, p)
+		}
+	}
+}
+
+func TestInventoryPattern(t *testing.T) {
+	cases := map[string]string{
+		"datastore1":          "datastore1",
+		"ISO Store (Prod)":    "ISO Store (Prod)",
+		"ISO [Prod]":          "ISO [[]Prod]",
+		"/dc1/datastore/ds*?": "/dc1/datastore/ds[*][?]",
+	}
+
+	for name, expected := range cases {
+		actual := inventoryPattern(name)
+		if actual != expected {
+			t.Fatalf("%s: expected %s, got %s", name, expected, actual)
+		}
+		matched, err := filepath.Match(actual, name)
+		if err != nil || !matched {
+			t.Fatalf("%s: expected %s to match it, got %t, %v", name, actual, matched, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+
+	"github.com/hashicorp/terraform/helper/mutexkv"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// datastoreIDPattern matches the managed object ID of a datastore on
+// vCenter, e.g. "datastore-123".
+var datastoreIDPattern = regexp.MustCompile(`^datastore-[0-9]+$`)
+
+// lookupCache memoizes datacenter and datastore lookups. The provider
+// configures a new client for every run, so keeping one cache per client
+// means objects are resolved at most once per run, no matter how many
+// resources refer to them. Only successful lookups are cached.
+type lookupCache struct {
+	sync.Mutex
+	// lookups serializes lookups of the same object, so it is resolved once,
+	// while objects of other keys are looked up concurrently. The embedded
+	// mutex only guards the maps.
+	lookups     *mutexkv.MutexKV
+	datacenters map[string]*object.Datacenter
+	datastores  map[string]*object.Datastore
+}
+
+var (
+	lookupCachesLock sync.Mutex
+	lookupCaches     = map[*govmomi.Client]*lookupCache{}
+)
+
+// clientLookupCache returns the lookup cache of a client, creating it on
+// first use.
+func clientLookupCache(c *govmomi.Client) *lookupCache {
+	lookupCachesLock.Lock()
+	defer lookupCachesLock.Unlock()
+
+	cache, ok := lookupCaches[c]
+	if !ok {
+		cache = newLookupCache()
+		lookupCaches[c] = cache
+	}
+	return cache
+}
+
+// deleteLookupCache drops the lookup cache of a client that was logged out.
+func deleteLookupCache(c *govmomi.Client) {
+	lookupCachesLock.Lock()
+	defer lookupCachesLock.Unlock()
+	delete(lookupCaches, c)
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{
+		lookups:     mutexkv.NewMutexKV(),
+		datacenters: map[string]*object.Datacenter{},
+		datastores:  map[string]*object.Datastore{},
+	}
+}
+
+// datacenter returns the cached datacenter for name, calling lookup if it
+// hasn't been resolved yet.
+func (c *lookupCache) datacenter(name string, lookup func() (*object.Datacenter, error)) (*object.Datacenter, error) {
+	key := "datacenter/" + name
+	c.lookups.Lock(key)
+	defer c.lookups.Unlock(key)
+
+	c.Lock()
+	dc, ok := c.datacenters[name]
+	c.Unlock()
+	if ok {
+		return dc, nil
+	}
+
+	dc, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	c.datacenters[name] = dc
+	c.Unlock()
+	return dc, nil
+}
+
+// datastore returns the cached datastore for name in the datacenter dc,
+// calling lookup if it hasn't been resolved yet.
+func (c *lookupCache) datastore(dc *object.Datacenter, name string, lookup func() (*object.Datastore, error)) (*object.Datastore, error) {
+	key := dc.Reference().Value + "/" + name
+	c.lookups.Lock("datastore/" + key)
+	defer c.lookups.Unlock("datastore/" + key)
+
+	c.Lock()
+	ds, ok := c.datastores[key]
+	c.Unlock()
+	if ok {
+		return ds, nil
+	}
+
+	ds, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	c.datastores[key] = ds
+	c.Unlock()
+	return ds, nil
+}
+
+// lookupDatastore gets the datastore called name in the datacenter dc, falling
+// back to the default datastore when no name is given
+func lookupDatastore(c *govmomi.Client, dc *object.Datacenter, name string) (*object.Datastore, error) {
+	return lookupDatastoreContext(context.TODO(), c, dc, name)
+}
+
+// lookupDatastoreContext is lookupDatastore, bounded by ctx. name can also be
+// the managed object ID of the datastore, which unlike its name is unique
+// across datacenters.
+func lookupDatastoreContext(ctx context.Context, c *govmomi.Client, dc *object.Datacenter, name string) (*object.Datastore, error) {
+	return clientLookupCache(c).datastore(dc, name, func() (*object.Datastore, error) {
+		if isDatastoreID(name) {
+			ds, err := getDatastoreByID(ctx, c, dc, name)
+			if !isDatastoreNotFoundError(err) {
+				return ds, err
+			}
+			// A datastore may also be named like an ID
+			log.Printf("[DEBUG] no datastore has the ID %s, looking it up by name", name)
+		}
+
+		finder := find.NewFinder(c.Client, true)
+		finder = finder.SetDatacenter(dc)
+		return getDatastoreContext(ctx, finder, name)
+	})
+}
+
+// isDatastoreID reports whether name is in the form of a datastore's managed
+// object ID.
+func isDatastoreID(name string) bool {
+	return datastoreIDPattern.MatchString(name)
+}
+
+// getDatastoreByID gets the datastore with the managed object ID id, which
+// must be in the datacenter dc.
+func getDatastoreByID(ctx context.Context, c *govmomi.Client, dc *object.Datacenter, id string) (*object.Datastore, error) {
+	ref := types.ManagedObjectReference{Type: "Datastore", Value: id}
+
+	var mds mo.Datastore
+	collector := property.DefaultCollector(c.Client)
+	err := collector.RetrieveOne(ctx, ref, []string{"name"}, &mds)
+	if err != nil {
+		if soap.IsSoapFault(err) {
+			if _, ok := soap.ToSoapFault(err).VimFault().(types.ManagedObjectNotFound); ok {
+				return nil, &datastoreNotFoundError{name: id}
+			}
+		}
+		return nil, fmt.Errorf("error reading datastore %s: %s", id, err)
+	}
+
+	var mdc mo.Datacenter
+	err = collector.RetrieveOne(ctx, dc.Reference(), []string{"name", "datastore"}, &mdc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading datastores of datacenter: %s", err)
+	}
+	if !containsReference(mdc.Datastore, ref) {
+		return nil, fmt.Errorf("datastore %s (%s) is not in datacenter %s", id, mds.Name, mdc.Name)
+	}
+
+	ds := object.NewDatastore(c.Client, ref)
+	// Datastore.Name and Path only use the last element of the path
+	ds.InventoryPath = mds.Name
+	return ds, nil
+}
+
+// containsReference reports whether refs contains ref.
+func containsReference(refs []types.ManagedObjectReference, ref types.ManagedObjectReference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package vsphere
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestLookupCache(t *testing.T) {
+	cache := newLookupCache()
+	dc := object.NewDatacenter(nil, types.ManagedObjectReference{Type: "Datacenter", Value: "datacenter-2"})
+	otherDc := object.NewDatacenter(nil, types.ManagedObjectReference{Type: "Datacenter", Value: "datacenter-3"})
+
+	dcLookups := 0
+	dcLookup := func() (*object.Datacenter, error) {
+		dcLookups++
+		return dc, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		actual, err := cache.datacenter("dc1", dcLookup)
+		if err != nil {
+			t.Fatalf("error %s", err)
+		}
+		if actual != dc {
+			t.Fatalf("expected cached datacenter %v, got %v", dc, actual)
+		}
+	}
+	if dcLookups != 1 {
+		t.Fatalf("expected 1 datacenter lookup for 10 calls, got %d", dcLookups)
+	}
+
+	dsLookups := 0
+	dsLookup := func() (*object.Datastore, error) {
+		dsLookups++
+		return object.NewDatastore(nil, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}), nil
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := cache.datastore(dc, "local", dsLookup); err != nil {
+			t.Fatalf("error %s", err)
+		}
+	}
+	if _, err := cache.datastore(otherDc, "local", dsLookup); err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if dsLookups != 2 {
+		t.Fatalf("expected 2 datastore lookups for 11 calls in 2 datacenters, got %d", dsLookups)
+	}
+
+	failures := 0
+	failingLookup := func() (*object.Datastore, error) {
+		failures++
+		return nil, errors.New("datastore 'missing' not found")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.datastore(dc, "missing", failingLookup); err == nil {
+			t.Fatalf("expected error for missing datastore")
+		}
+	}
+	if failures != 2 {
+		t.Fatalf("expected failed lookups not to be cached, got %d lookups", failures)
+	}
+}
+
+func TestLookupCacheConcurrent(t *testing.T) {
+	cache := newLookupCache()
+	dc := object.NewDatacenter(nil, types.ManagedObjectReference{Type: "Datacenter", Value: "datacenter-2"})
+
+	// The lookup of slow only finishes once fast has been looked up, which
+	// deadlocks if lookups of different datastores are serialized.
+	fastDone := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cache.datastore(dc, "slow", func() (*object.Datastore, error) {
+			<-fastDone
+			return object.NewDatastore(nil, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}), nil
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+		cache.datastore(dc, "fast", func() (*object.Datastore, error) {
+			return object.NewDatastore(nil, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-2"}), nil
+		})
+		close(fastDone)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup of one datastore blocked the lookup of another")
+	}
+
+	// Concurrent lookups of the same datastore still resolve it once
+	var mu sync.Mutex
+	lookups := 0
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			cache.datastore(dc, "shared", func() (*object.Datastore, error) {
+				mu.Lock()
+				lookups++
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				return object.NewDatastore(nil, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-3"}), nil
+			})
+		}()
+	}
+	wg.Wait()
+	if lookups != 1 {
+		t.Fatalf("expected 1 lookup for 10 concurrent calls, got %d", lookups)
+	}
+}
+
+func TestIsDatastoreID(t *testing.T) {
+	cases := map[string]bool{
+		"datastore-123":   true,
+		"datastore-1":     true,
+		"datastore1":      false,
+		"datastore-":      false,
+		"datastore-12a":   false,
+		"my datastore-12": false,
+		"":                false,
+	}
+
+	for name, expected := range cases {
+		if actual := isDatastoreID(name); actual != expected {
+			t.Errorf("%q: expected %t, got %t", name, expected, actual)
+		}
+	}
+}
+
+func TestContainsReference(t *testing.T) {
+	refs := []types.ManagedObjectReference{
+		{Type: "Datastore", Value: "datastore-11"},
+		{Type: "Datastore", Value: "datastore-12"},
+	}
+
+	if !containsReference(refs, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-12"}) {
+		t.Fatal("expected datastore-12 to be found")
+	}
+	if containsReference(refs, types.ManagedObjectReference{Type: "Datastore", Value: "datastore-13"}) {
+		t.Fatal("expected datastore-13 not to be found")
+	}
+}
@@ -2,29 +2,42 @@ package vsphere
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/hashicorp/terraform/helper/mutexkv"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
 
+// vsphereMutexKV serializes changes to the same datastore object across
+// resources.
+var vsphereMutexKV = mutexkv.NewMutexKV()
+
 // Provider returns a terraform.ResourceProvider.
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"user": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_USER", nil),
 				Description: "The user name for vSphere API operations.",
 			},
 
 			"password": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_PASSWORD", nil),
 				Description: "The user password for vSphere API operations.",
 			},
 
+			"credentials_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_CREDENTIALS_FILE", ""),
+				Description: "A JSON file with user and password, read again at every login so rotated credentials are picked up.",
+			},
+
 			"vsphere_server": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -61,13 +74,64 @@ func Provider() terraform.ResourceProvider {
 				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_CLIENT_DEBUG_PATH", ""),
 				Description: "govomomi debug path for debug",
 			},
+			"client_keepalive": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_CLIENT_KEEPALIVE", "0"),
+				Description: "How long the session may be idle before a keepalive request is sent, 0 for none.",
+			},
+			"client_timeout": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_CLIENT_TIMEOUT", "0"),
+				Description: "The timeout of a single request to vSphere, including file transfers, 0 for none.",
+			},
+			"client_user_agent": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_CLIENT_USER_AGENT", ""),
+				Description: "A string added in front of the User-Agent of requests to vSphere, to identify this automation.",
+			},
+			"read_only": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_READ_ONLY", false),
+				Description: "Refuse to create, change or delete anything, while still reading resources and data sources.",
+			},
+			"task_poll_interval": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_TASK_POLL_INTERVAL", "0"),
+				Description: "The delay between the first polls of a vSphere task, doubling after each poll. 0 watches tasks for updates instead.",
+			},
+			"task_poll_max_interval": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VSPHERE_TASK_POLL_MAX_INTERVAL", "30s"),
+				Description: "The longest delay between two polls of a vSphere task.",
+			},
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"vsphere_datastore":              dataSourceVSphereDatastore(),
+			"vsphere_datastore_by_attribute": dataSourceVSphereDatastoreByAttribute(),
+			"vsphere_datastore_file":         dataSourceVSphereDatastoreFile(),
+			"vsphere_datastore_files":        dataSourceVSphereDatastoreFiles(),
+			"vsphere_datastores":             dataSourceVSphereDatastores(),
+			"vsphere_folder":                 dataSourceVSphereFolder(),
+			"vsphere_health":                 dataSourceVSphereHealth(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"vsphere_file":            resourceVSphereFile(),
-			"vsphere_folder":          resourceVSphereFolder(),
-			"vsphere_virtual_disk":    resourceVSphereVirtualDisk(),
-			"vsphere_virtual_machine": resourceVSphereVirtualMachine(),
+			"vsphere_datastore_file_copy": resourceVSphereDatastoreFileCopy(),
+			"vsphere_datastore_folder":    resourceVSphereDatastoreFolder(),
+			"vsphere_directory_upload":    resourceVSphereDirectoryUpload(),
+			"vsphere_file":                resourceVSphereFile(),
+			"vsphere_file_absent":         resourceVSphereFileAbsent(),
+			"vsphere_file_set":            resourceVSphereFileSet(),
+			"vsphere_folder":              resourceVSphereFolder(),
+			"vsphere_virtual_disk":        resourceVSphereVirtualDisk(),
+			"vsphere_virtual_machine":     resourceVSphereVirtualMachine(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -89,14 +153,52 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 			"One of vsphere_server or [deprecated] vcenter_server must be provided.")
 	}
 
+	user := d.Get("user").(string)
+	password := d.Get("password").(string)
+	credentialsFile := d.Get("credentials_file").(string)
+	if credentialsFile == "" && (user == "" || password == "") {
+		return nil, fmt.Errorf(
+			"Either user and password or credentials_file must be provided.")
+	}
+
+	keepAlive, err := time.ParseDuration(d.Get("client_keepalive").(string))
+	if err != nil {
+		return nil, fmt.Errorf("client_keepalive cannot be parsed as a duration: %s", err)
+	}
+
+	timeout, err := time.ParseDuration(d.Get("client_timeout").(string))
+	if err != nil {
+		return nil, fmt.Errorf("client_timeout cannot be parsed as a duration: %s", err)
+	}
+
+	taskPollInterval, err := time.ParseDuration(d.Get("task_poll_interval").(string))
+	if err != nil {
+		return nil, fmt.Errorf("task_poll_interval cannot be parsed as a duration: %s", err)
+	}
+
+	taskPollMaxInterval, err := time.ParseDuration(d.Get("task_poll_max_interval").(string))
+	if err != nil {
+		return nil, fmt.Errorf("task_poll_max_interval cannot be parsed as a duration: %s", err)
+	}
+
 	config := Config{
-		User:          d.Get("user").(string),
-		Password:      d.Get("password").(string),
-		InsecureFlag:  d.Get("allow_unverified_ssl").(bool),
-		VSphereServer: server,
-		Debug:         d.Get("client_debug").(bool),
-		DebugPathRun:  d.Get("client_debug_path_run").(string),
-		DebugPath:     d.Get("client_debug_path").(string),
+		User:                user,
+		Password:            password,
+		InsecureFlag:        d.Get("allow_unverified_ssl").(bool),
+		VSphereServer:       server,
+		Debug:               d.Get("client_debug").(bool),
+		DebugPathRun:        d.Get("client_debug_path_run").(string),
+		DebugPath:           d.Get("client_debug_path").(string),
+		KeepAlive:           keepAlive,
+		Timeout:             timeout,
+		UserAgent:           d.Get("client_user_agent").(string),
+		ReadOnly:            d.Get("read_only").(bool),
+		TaskPollInterval:    taskPollInterval,
+		TaskPollMaxInterval: taskPollMaxInterval,
+	}
+
+	if credentialsFile != "" {
+		config.Credentials = fileCredentials{path: credentialsFile}
 	}
 
 	return config.Client()
@@ -2,10 +2,13 @@ package vsphere
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25"
 )
 
 var testAccProviders map[string]terraform.ResourceProvider
@@ -42,3 +45,21 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal("VSPHERE_SERVER must be set for acceptance tests")
 	}
 }
+
+func TestCheckWritable(t *testing.T) {
+	rw := &govmomi.Client{Client: &vim25.Client{}}
+	ro := &govmomi.Client{Client: &vim25.Client{}}
+	setClientSettings(ro.Client, clientSettings{readOnly: true})
+
+	if err := checkWritable(rw, "create vsphere_file"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := checkWritable(ro, "create vsphere_file")
+	if err == nil {
+		t.Fatal("expected an error for a read-only provider")
+	}
+	if !strings.Contains(err.Error(), "create vsphere_file") {
+		t.Fatalf("error does not name the operation: %s", err)
+	}
+}
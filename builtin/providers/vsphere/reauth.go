@@ -0,0 +1,92 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// reauthRoundTripper logs in again when vSphere rejects a call because the
+// session has expired, e.g. after an upload that took longer than the
+// session timeout, and retries the call once. A call rejected this way was
+// never run, so retrying is safe for calls that change something too.
+type reauthRoundTripper struct {
+	soap.RoundTripper
+
+	// login logs in with the credentials the client was created with.
+	login func(ctx context.Context) error
+
+	loginLock sync.Mutex
+	// logins counts the successful logins, so that calls failing on the
+	// same expired session log in only once.
+	logins uint64
+}
+
+func newReauthRoundTripper(rt soap.RoundTripper, login func(ctx context.Context) error) *reauthRoundTripper {
+	return &reauthRoundTripper{
+		RoundTripper: rt,
+		login:        login,
+	}
+}
+
+func (rt *reauthRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	logins := atomic.LoadUint64(&rt.logins)
+
+	err := rt.RoundTripper.RoundTrip(ctx, req, res)
+	if !isNotAuthenticatedError(err) {
+		return err
+	}
+	if _, ok := req.(*methods.LoginBody); ok {
+		return err
+	}
+
+	if err := rt.relogin(ctx, logins); err != nil {
+		return fmt.Errorf("error logging in again after the session expired: %s", err)
+	}
+
+	// The response body still holds the fault of the first call, which
+	// decoding the retry's response would not clear.
+	v := reflect.ValueOf(res).Elem()
+	v.Set(reflect.Zero(v.Type()))
+
+	return rt.RoundTripper.RoundTrip(ctx, req, res)
+}
+
+// relogin logs in again, unless another call already did since logins was
+// read.
+func (rt *reauthRoundTripper) relogin(ctx context.Context, logins uint64) error {
+	rt.loginLock.Lock()
+	defer rt.loginLock.Unlock()
+
+	if atomic.LoadUint64(&rt.logins) != logins {
+		return nil
+	}
+
+	log.Printf("[WARN] vSphere session expired, logging in again")
+	if err := rt.login(ctx); err != nil {
+		return err
+	}
+	atomic.AddUint64(&rt.logins, 1)
+	return nil
+}
+
+// isNotAuthenticatedError returns whether err is the fault of a call made
+// with a session that has expired or was logged out.
+func isNotAuthenticatedError(err error) bool {
+	if err == nil || !soap.IsSoapFault(err) {
+		return false
+	}
+
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.NotAuthenticated, *types.NotAuthenticated:
+		return true
+	}
+	return false
+}
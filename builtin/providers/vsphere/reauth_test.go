@@ -0,0 +1,95 @@
+package vsphere
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// expiringRoundTripper answers calls with NotAuthenticated until login is
+// called, like vCenter does once a session has expired.
+type expiringRoundTripper struct {
+	expired bool
+	calls   int
+}
+
+func (rt *expiringRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	rt.calls++
+	body := res.(*methods.CurrentTimeBody)
+	if rt.expired {
+		body.Fault_ = notAuthenticatedFault()
+		return soap.WrapSoapFault(body.Fault_)
+	}
+	body.Res = &types.CurrentTimeResponse{}
+	return nil
+}
+
+func notAuthenticatedFault() *soap.Fault {
+	f := &soap.Fault{Code: "ServerFaultCode", String: "The session is not authenticated."}
+	f.Detail.Fault = types.NotAuthenticated{}
+	return f
+}
+
+func TestReauthRoundTripper(t *testing.T) {
+	inner := &expiringRoundTripper{expired: true}
+	logins := 0
+	rt := newReauthRoundTripper(inner, func(ctx context.Context) error {
+		logins++
+		inner.expired = false
+		return nil
+	})
+
+	res := &methods.CurrentTimeBody{}
+	err := rt.RoundTrip(context.TODO(), &methods.CurrentTimeBody{}, res)
+	if err != nil {
+		t.Fatalf("expected the call to succeed after logging in again, got %s", err)
+	}
+	if logins != 1 || inner.calls != 2 {
+		t.Fatalf("expected 1 login and 2 calls, got %d and %d", logins, inner.calls)
+	}
+	if res.Fault_ != nil || res.Res == nil {
+		t.Fatalf("expected the response of the retry, got %#v", res)
+	}
+
+	// A session that expires again is renewed again
+	inner.expired = true
+	err = rt.RoundTrip(context.TODO(), &methods.CurrentTimeBody{}, &methods.CurrentTimeBody{})
+	if err != nil || logins != 2 {
+		t.Fatalf("expected a second login, got %d logins and error %v", logins, err)
+	}
+}
+
+func TestReauthRoundTripper_loginFails(t *testing.T) {
+	inner := &expiringRoundTripper{expired: true}
+	rt := newReauthRoundTripper(inner, func(ctx context.Context) error {
+		return errors.New("Cannot complete login due to an incorrect user name or password.")
+	})
+
+	err := rt.RoundTrip(context.TODO(), &methods.CurrentTimeBody{}, &methods.CurrentTimeBody{})
+	expected := "error logging in again after the session expired: Cannot complete login due to an incorrect user name or password."
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected no retry without a session, got %d calls", inner.calls)
+	}
+}
+
+func TestIsNotAuthenticatedError(t *testing.T) {
+	if !isNotAuthenticatedError(soap.WrapSoapFault(notAuthenticatedFault())) {
+		t.Fatal("expected NotAuthenticated fault to be detected")
+	}
+
+	other := &soap.Fault{Code: "ServerFaultCode"}
+	other.Detail.Fault = types.FileNotFound{}
+	if isNotAuthenticatedError(soap.WrapSoapFault(other)) {
+		t.Fatal("expected other faults not to be NotAuthenticated")
+	}
+	if isNotAuthenticatedError(errors.New("connection refused")) {
+		t.Fatal("expected other errors not to be NotAuthenticated")
+	}
+}
@@ -0,0 +1,226 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+// datastoreFileLocation is a file on a datastore, as given by the source and
+// destination blocks of vsphere_datastore_file_copy.
+type datastoreFileLocation struct {
+	datacenter string
+	datastore  string
+	path       string
+}
+
+func resourceVSphereDatastoreFileCopy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVSphereDatastoreFileCopyCreate,
+		Read:   resourceVSphereDatastoreFileCopyRead,
+		Update: resourceVSphereDatastoreFileCopyUpdate,
+		Delete: resourceVSphereDatastoreFileCopyDelete,
+
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem:     datastoreFileLocationSchema(),
+			},
+
+			"destination": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem:     datastoreFileLocationSchema(),
+			},
+
+			"force": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func datastoreFileLocationSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+		},
+	}
+}
+
+// expandDatastoreFileLocation reads a source or destination block.
+func expandDatastoreFileLocation(v interface{}) datastoreFileLocation {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return datastoreFileLocation{}
+	}
+	m := l[0].(map[string]interface{})
+	return datastoreFileLocation{
+		datacenter: m["datacenter"].(string),
+		datastore:  m["datastore"].(string),
+		path:       normalizeDatastorePath(m["path"].(string)),
+	}
+}
+
+// find returns the datacenter and datastore of l.
+func (l datastoreFileLocation) find(ctx context.Context, client *govmomi.Client) (*object.Datacenter, *object.Datastore, error) {
+	dc, err := getDatacenterContext(ctx, client, l.datacenter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding datacenter %q: %s", l.datacenter, err)
+	}
+
+	ds, err := lookupDatastoreContext(ctx, client, dc, l.datastore)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding datastore %q: %s", l.datastore, err)
+	}
+	return dc, ds, nil
+}
+
+func resourceVSphereDatastoreFileCopyCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_datastore_file_copy"); err != nil {
+		return err
+	}
+
+	client := meta.(*govmomi.Client)
+	ctx := context.TODO()
+
+	source := expandDatastoreFileLocation(d.Get("source"))
+	destination := expandDatastoreFileLocation(d.Get("destination"))
+	log.Printf("[DEBUG] creating datastore file copy of [%s] %s to [%s] %s",
+		source.datastore, source.path, destination.datastore, destination.path)
+
+	if err := validateDatastorePath(source.path); err != nil {
+		return fmt.Errorf("source path %s", err)
+	}
+	if err := validateDatastorePath(destination.path); err != nil {
+		return fmt.Errorf("destination path %s", err)
+	}
+
+	sourceDc, sourceDs, err := source.find(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	dc, ds, err := destination.find(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if !d.Get("force").(bool) {
+		_, err := ds.Stat(ctx, destination.path)
+		if err == nil {
+			return fmt.Errorf("destination %s already exists, set force to overwrite it", ds.Path(destination.path))
+		}
+		if !isFileNotFoundError(err) {
+			return fmt.Errorf("error reading destination %s: %s", ds.Path(destination.path), err)
+		}
+	}
+
+	fm := object.NewFileManager(client.Client)
+	task, err := fm.CopyDatastoreFile(ctx, sourceDs.Path(source.path), sourceDc, ds.Path(destination.path), dc, d.Get("force").(bool))
+	if err != nil {
+		return fmt.Errorf("error copying %s to %s: %s", sourceDs.Path(source.path), ds.Path(destination.path), err)
+	}
+
+	_, err = waitForTask(ctx, task, nil)
+	if err != nil {
+		return fmt.Errorf("error copying %s to %s: %s", sourceDs.Path(source.path), ds.Path(destination.path), err)
+	}
+
+	d.SetId(datastoreObjectID(destination.datastore, destination.datacenter, destination.path))
+	log.Printf("[INFO] Copied %s to %s", sourceDs.Path(source.path), ds.Path(destination.path))
+
+	return resourceVSphereDatastoreFileCopyRead(d, meta)
+}
+
+func resourceVSphereDatastoreFileCopyRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading datastore file copy %s", d.Id())
+	client := meta.(*govmomi.Client)
+	ctx := context.TODO()
+
+	destination := expandDatastoreFileLocation(d.Get("destination"))
+	_, ds, err := destination.find(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	info, err := ds.Stat(ctx, destination.path)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			log.Printf("[INFO] copied file %s is gone", ds.Path(destination.path))
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("error reading %s: %s", ds.Path(destination.path), err)
+	}
+
+	d.Set("size", info.GetFileInfo().FileSize)
+	return nil
+}
+
+func resourceVSphereDatastoreFileCopyUpdate(d *schema.ResourceData, meta interface{}) error {
+	// Only force can change, which is used by Create alone.
+	return nil
+}
+
+func resourceVSphereDatastoreFileCopyDelete(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "delete vsphere_datastore_file_copy"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] deleting datastore file copy %s", d.Id())
+	client := meta.(*govmomi.Client)
+	ctx := context.TODO()
+
+	destination := expandDatastoreFileLocation(d.Get("destination"))
+	dc, ds, err := destination.find(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	fm := object.NewFileManager(client.Client)
+	task, err := fm.DeleteDatastoreFile(ctx, ds.Path(destination.path), dc)
+	if err != nil {
+		return fmt.Errorf("error deleting %s: %s", ds.Path(destination.path), err)
+	}
+
+	_, err = waitForTask(ctx, task, nil)
+	if err != nil && !isFileNotFoundError(err) {
+		return fmt.Errorf("error deleting %s: %s", ds.Path(destination.path), err)
+	}
+
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,138 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"golang.org/x/net/context"
+)
+
+// Server-side copy of an uploaded file
+func TestAccVSphereDatastoreFileCopy_basic(t *testing.T) {
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	resourceName := "vsphere_datastore_file_copy.copy"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVSphereDatastoreFileCopyDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDatastoreFileCopyConfig,
+					datacenter,
+					datastore,
+					datacenter,
+					datastore,
+					datacenter,
+					datastore,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereDatastoreFileCopyExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "destination.0.path", "tf_test_copy/hosts.copy"),
+					resource.TestCheckResourceAttr(resourceName, "size", "18"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVSphereDatastoreFileCopyDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*govmomi.Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "vsphere_datastore_file_copy" {
+			continue
+		}
+
+		dc, err := getDatacenter(client, rs.Primary.Attributes["destination.0.datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		ds, err := lookupDatastore(client, dc, rs.Primary.Attributes["destination.0.datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		_, err = ds.Stat(context.TODO(), rs.Primary.Attributes["destination.0.path"])
+		if err == nil {
+			return fmt.Errorf("Copied file %s still exists", rs.Primary.Attributes["destination.0.path"])
+		}
+		if !isFileNotFoundError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccCheckVSphereDatastoreFileCopyExists(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Resource not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*govmomi.Client)
+		dc, err := getDatacenter(client, rs.Primary.Attributes["destination.0.datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		ds, err := lookupDatastore(client, dc, rs.Primary.Attributes["destination.0.datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		_, err = ds.Stat(context.TODO(), rs.Primary.Attributes["destination.0.path"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		return nil
+	}
+}
+
+const testAccCheckVSphereDatastoreFileCopyConfig = `
+resource "vsphere_file" "upload" {
+	datacenter = "%s"
+	datastore = "%s"
+	content = "hostname=terraform"
+	destination_file = "tf_test_copy/hosts"
+	create_directories = true
+}
+
+resource "vsphere_datastore_file_copy" "copy" {
+	source {
+		datacenter = "%s"
+		datastore = "%s"
+		path = "${vsphere_file.upload.destination_file}"
+	}
+
+	destination {
+		datacenter = "%s"
+		datastore = "%s"
+		path = "tf_test_copy/hosts.copy"
+	}
+}
+`
+
+func TestExpandDatastoreFileLocation(t *testing.T) {
+	l := expandDatastoreFileLocation([]interface{}{
+		map[string]interface{}{
+			"datacenter": "dc1",
+			"datastore":  "datastore1",
+			"path":       "[datastore1] iso//ubuntu.iso",
+		},
+	})
+
+	if l.datacenter != "dc1" || l.datastore != "datastore1" || l.path != "iso/ubuntu.iso" {
+		t.Fatalf("unexpected location %#v", l)
+	}
+}
@@ -0,0 +1,192 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+type datastoreFolder struct {
+	datacenter     string
+	datastore      string
+	path           string
+	deleteNonEmpty bool
+}
+
+func resourceVSphereDatastoreFolder() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVSphereDatastoreFolderCreate,
+		Read:   resourceVSphereDatastoreFolderRead,
+		Update: resourceVSphereDatastoreFolderUpdate,
+		Delete: resourceVSphereDatastoreFolderDelete,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				StateFunc: func(v interface{}) string {
+					return strings.Trim(normalizeDatastorePath(v.(string)), "/")
+				},
+			},
+
+			"delete_non_empty": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func resourceVSphereDatastoreFolderCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_datastore_folder"); err != nil {
+		return err
+	}
+
+	client := meta.(*govmomi.Client)
+
+	f := datastoreFolder{
+		datastore: d.Get("datastore").(string),
+		path:      strings.Trim(normalizeDatastorePath(d.Get("path").(string)), "/"),
+	}
+
+	if v, ok := d.GetOk("datacenter"); ok {
+		f.datacenter = v.(string)
+	}
+	log.Printf("[DEBUG] creating datastore folder [%s] %s", f.datastore, f.path)
+
+	if f.path == "" {
+		return fmt.Errorf("path must not be the root of the datastore")
+	}
+
+	dc, err := getDatacenter(client, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", f.datacenter, err)
+	}
+
+	ds, err := lookupDatastore(client, dc, f.datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", f.datastore, err)
+	}
+
+	fm := object.NewFileManager(client.Client)
+	err = fm.MakeDirectory(context.TODO(), ds.Path(f.path), dc, true)
+	if err != nil {
+		return fmt.Errorf("error creating directory %s: %s", ds.Path(f.path), err)
+	}
+
+	d.SetId(datastoreObjectID(f.datastore, f.datacenter, f.path))
+	log.Printf("[INFO] Created datastore folder: %s", ds.Path(f.path))
+
+	return resourceVSphereDatastoreFolderRead(d, meta)
+}
+
+func resourceVSphereDatastoreFolderRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading datastore folder %s", d.Id())
+	client := meta.(*govmomi.Client)
+
+	datacenter := d.Get("datacenter").(string)
+	dc, err := getDatacenter(client, datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", datacenter, err)
+	}
+
+	datastore := d.Get("datastore").(string)
+	ds, err := lookupDatastore(client, dc, datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", datastore, err)
+	}
+
+	p := d.Get("path").(string)
+	_, err = ds.Stat(context.TODO(), p)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			log.Printf("[INFO] datastore folder %s is gone", ds.Path(p))
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("error reading datastore folder %s: %s", ds.Path(p), err)
+	}
+
+	return nil
+}
+
+func resourceVSphereDatastoreFolderUpdate(d *schema.ResourceData, meta interface{}) error {
+	// Only delete_non_empty can change, which is used by Delete alone.
+	return nil
+}
+
+func resourceVSphereDatastoreFolderDelete(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "delete vsphere_datastore_folder"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] deleting datastore folder %s", d.Id())
+	client := meta.(*govmomi.Client)
+
+	f := datastoreFolder{
+		datacenter:     d.Get("datacenter").(string),
+		datastore:      d.Get("datastore").(string),
+		path:           d.Get("path").(string),
+		deleteNonEmpty: d.Get("delete_non_empty").(bool),
+	}
+
+	dc, err := getDatacenter(client, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", f.datacenter, err)
+	}
+
+	ds, err := lookupDatastore(client, dc, f.datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", f.datastore, err)
+	}
+
+	if !f.deleteNonEmpty {
+		files, err := listDatastoreDirectory(context.TODO(), ds, f.path)
+		if err != nil {
+			if isFileNotFoundError(err) {
+				d.SetId("")
+				return nil
+			}
+			return fmt.Errorf("error listing datastore folder %s: %s", ds.Path(f.path), err)
+		}
+		if len(files) > 0 {
+			return fmt.Errorf("datastore folder %s is not empty, set delete_non_empty to delete it with its contents", ds.Path(f.path))
+		}
+	}
+
+	fm := object.NewFileManager(client.Client)
+	task, err := fm.DeleteDatastoreFile(context.TODO(), ds.Path(f.path), dc)
+	if err != nil {
+		return fmt.Errorf("error deleting datastore folder %s: %s", ds.Path(f.path), err)
+	}
+
+	_, err = waitForTask(context.TODO(), task, nil)
+	if err != nil && !isFileNotFoundError(err) {
+		return fmt.Errorf("error deleting datastore folder %s: %s", ds.Path(f.path), err)
+	}
+
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,111 @@
+package vsphere
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"golang.org/x/net/context"
+)
+
+// Creation of a nested datastore folder
+func TestAccVSphereDatastoreFolder_basic(t *testing.T) {
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	resourceName := "vsphere_datastore_folder.logs"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVSphereDatastoreFolderDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDatastoreFolderConfig,
+					datacenter,
+					datastore,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereDatastoreFolderExists(resourceName, true),
+					resource.TestCheckResourceAttr(resourceName, "path", "tf_test_folder/logs"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVSphereDatastoreFolderDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*govmomi.Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "vsphere_datastore_folder" {
+			continue
+		}
+
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		ds, err := lookupDatastore(client, dc, rs.Primary.Attributes["datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		_, err = ds.Stat(context.TODO(), rs.Primary.Attributes["path"])
+		if err == nil {
+			return fmt.Errorf("Datastore folder %s still exists", rs.Primary.Attributes["path"])
+		}
+		if !isFileNotFoundError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccCheckVSphereDatastoreFolderExists(n string, exists bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Resource not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*govmomi.Client)
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		ds, err := lookupDatastore(client, dc, rs.Primary.Attributes["datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		_, err = ds.Stat(context.TODO(), rs.Primary.Attributes["path"])
+		if err != nil {
+			if isFileNotFoundError(err) {
+				if exists {
+					return fmt.Errorf("datastore folder %s does not exist", rs.Primary.Attributes["path"])
+				}
+				return nil
+			}
+			return fmt.Errorf("error %s", err)
+		}
+		if !exists {
+			return fmt.Errorf("datastore folder %s still exists", rs.Primary.Attributes["path"])
+		}
+		return nil
+	}
+}
+
+const testAccCheckVSphereDatastoreFolderConfig = `
+resource "vsphere_datastore_folder" "logs" {
+	datacenter = "%s"
+	datastore = "%s"
+	path = "tf_test_folder/logs"
+}
+`
@@ -0,0 +1,525 @@
+package vsphere
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/net/context"
+)
+
+type directoryUpload struct {
+	datacenter           string
+	datastore            string
+	sourceDirectory      string
+	destinationDirectory string
+	recursive            bool
+	parallelism          int
+	computeChecksums     bool
+	files                []string
+	directories          []string
+	checksums            map[string]string
+}
+
+func resourceVSphereDirectoryUpload() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVSphereDirectoryUploadCreate,
+		Read:   resourceVSphereDirectoryUploadRead,
+		Delete: resourceVSphereDirectoryUploadDelete,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"source_directory": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"destination_directory": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"recursive": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  true,
+			},
+
+			"parallelism": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: true,
+				Default:  4,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 1 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be at least 1", k))
+					}
+					return
+				},
+			},
+
+			"timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "30m",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					duration, err := time.ParseDuration(value)
+					if err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q cannot be parsed as a duration: %s", k, err))
+					}
+					if duration <= 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be greater than zero", k))
+					}
+					return
+				},
+			},
+
+			"compute_checksums": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+
+			"files": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"file_checksums": {
+				Type:     schema.TypeMap,
+				Computed: true,
+			},
+
+			"manifest_sha256": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"directories": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func resourceVSphereDirectoryUploadCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_directory_upload"); err != nil {
+		return err
+	}
+
+	client := meta.(*govmomi.Client)
+
+	u := directoryUpload{
+		datastore:            d.Get("datastore").(string),
+		sourceDirectory:      d.Get("source_directory").(string),
+		destinationDirectory: strings.Trim(d.Get("destination_directory").(string), "/"),
+		recursive:            d.Get("recursive").(bool),
+		parallelism:          d.Get("parallelism").(int),
+		computeChecksums:     d.Get("compute_checksums").(bool),
+	}
+
+	if v, ok := d.GetOk("datacenter"); ok {
+		u.datacenter = v.(string)
+	}
+	log.Printf("[DEBUG] creating directory upload of %s to [%s] %s", u.sourceDirectory, u.datastore, u.destinationDirectory)
+
+	fi, err := os.Stat(u.sourceDirectory)
+	if err != nil {
+		return fmt.Errorf("source_directory %q is not accessible: %s", u.sourceDirectory, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("source_directory %q is not a directory", u.sourceDirectory)
+	}
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = createDirectoryUpload(ctx, client, &u)
+
+	// Record whatever was uploaded, so a failed upload can still be destroyed.
+	d.Set("files", u.files)
+	d.Set("directories", u.directories)
+	if u.computeChecksums {
+		d.Set("file_checksums", u.checksums)
+		d.Set("manifest_sha256", checksumManifest(u.checksums))
+	}
+	if err != nil {
+		if len(u.files) > 0 || len(u.directories) > 0 {
+			d.SetId(datastoreObjectID(u.datastore, u.datacenter, u.destinationDirectory))
+		}
+		return timeoutError(ctx, "create", timeout, err)
+	}
+
+	d.SetId(datastoreObjectID(u.datastore, u.datacenter, u.destinationDirectory))
+	log.Printf("[INFO] Uploaded %d files to %s", len(u.files), u.destinationDirectory)
+
+	return resourceVSphereDirectoryUploadRead(d, meta)
+}
+
+func createDirectoryUpload(ctx context.Context, client *govmomi.Client, u *directoryUpload) error {
+
+	dc, err := getDatacenter(client, u.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", u.datacenter, err)
+	}
+
+	ds, err := lookupDatastore(client, dc, u.datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", u.datastore, err)
+	}
+
+	fm := object.NewFileManager(client.Client)
+
+	created, err := makeDirectory(ctx, fm, ds, dc, u.destinationDirectory)
+	if err != nil {
+		return err
+	}
+	u.directories = append(u.directories, created...)
+
+	// Create all directories while walking the tree, so they exist before
+	// any of the files in them are uploaded concurrently.
+	var uploads []fileUpload
+	err = filepath.Walk(u.sourceDirectory, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(u.sourceDirectory, localPath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		remotePath := path.Join(u.destinationDirectory, filepath.ToSlash(rel))
+
+		if info.IsDir() {
+			if !u.recursive {
+				return filepath.SkipDir
+			}
+			created, err := makeDirectory(ctx, fm, ds, dc, remotePath)
+			if err != nil {
+				return err
+			}
+			u.directories = append(u.directories, created...)
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			log.Printf("[DEBUG] skipping %s, not a regular file", localPath)
+			return nil
+		}
+
+		uploads = append(uploads, fileUpload{localPath: localPath, remotePath: remotePath})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The checksum is computed right before each upload, so it describes
+	// the content that was uploaded even if the file changes afterwards.
+	var mu sync.Mutex
+	u.checksums = make(map[string]string)
+	u.files, err = uploadFiles(ctx, u.parallelism, uploads, func(ctx context.Context, upload fileUpload) error {
+		var checksum string
+		if u.computeChecksums {
+			var err error
+			checksum, err = fileChecksum(upload.localPath, "sha256")
+			if err != nil {
+				return err
+			}
+		}
+
+		log.Printf("[DEBUG] uploading %s to %s", upload.localPath, ds.Path(upload.remotePath))
+		err := uploadDatastoreFile(ctx, client, ds, dc, upload.localPath, upload.remotePath)
+		if err != nil {
+			return fmt.Errorf("error uploading %s to %s: %s", upload.localPath, ds.Path(upload.remotePath), err)
+		}
+
+		if u.computeChecksums {
+			mu.Lock()
+			u.checksums[upload.remotePath] = checksum
+			mu.Unlock()
+		}
+		return nil
+	})
+	return err
+}
+
+// checksumManifest returns the sha256 of the manifest of checksums, a
+// sha256sum style line of "<checksum>  <path>" per file, sorted by path.
+func checksumManifest(checksums map[string]string) string {
+	paths := make([]string, 0, len(checksums))
+	for p := range checksums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s  %s\n", checksums[p], p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileUpload is a local file to upload to a datastore path
+type fileUpload struct {
+	localPath  string
+	remotePath string
+}
+
+// uploadFiles runs upload for each of uploads, with at most parallelism
+// uploads at a time. The first failure cancels the uploads that are still
+// running and is returned. The remote paths of the files that were uploaded
+// are returned in sorted order, also on failure.
+func uploadFiles(ctx context.Context, parallelism int, uploads []fileUpload, upload func(context.Context, fileUpload) error) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var uploaded []string
+	var firstErr error
+
+	jobs := make(chan fileUpload)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				err := upload(ctx, job)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("error uploading %s: %s", job.localPath, err)
+						cancel()
+					}
+				} else {
+					uploaded = append(uploaded, job.remotePath)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, job := range uploads {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Strings(uploaded)
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return uploaded, firstErr
+}
+
+// uploadDatastoreFile uploads a local file to the given datastore path
+func uploadDatastoreFile(ctx context.Context, client *govmomi.Client, ds *object.Datastore, dc *object.Datacenter, localPath string, remotePath string) error {
+	dsurl, err := ds.URL(ctx, dc, remotePath)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	p := soap.DefaultUpload
+	p.ContentLength = fi.Size()
+	if fi.Size() >= progressLogThreshold {
+		p.Progress = newProgressLogger(ctx, localPath)
+	}
+
+	return uploadContext(ctx, client, fh, dsurl, &p)
+}
+
+func resourceVSphereDirectoryUploadRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading directory upload %s", d.Id())
+	client := meta.(*govmomi.Client)
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	datacenter := d.Get("datacenter").(string)
+	dc, err := getDatacenter(client, datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", datacenter, err)
+	}
+
+	datastore := d.Get("datastore").(string)
+	ds, err := lookupDatastore(client, dc, datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", datastore, err)
+	}
+
+	for _, v := range d.Get("files").([]interface{}) {
+		_, err := ds.Stat(ctx, v.(string))
+		if err != nil {
+			if isFileNotFoundError(err) {
+				log.Printf("[INFO] uploaded file %s is gone", ds.Path(v.(string)))
+				d.SetId("")
+				return nil
+			}
+			return timeoutError(ctx, "read", timeout, fmt.Errorf("error reading %s: %s", ds.Path(v.(string)), err))
+		}
+	}
+
+	return nil
+}
+
+func resourceVSphereDirectoryUploadDelete(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "delete vsphere_directory_upload"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] deleting directory upload %s", d.Id())
+	client := meta.(*govmomi.Client)
+
+	u := directoryUpload{
+		datacenter: d.Get("datacenter").(string),
+		datastore:  d.Get("datastore").(string),
+	}
+
+	for _, v := range d.Get("files").([]interface{}) {
+		u.files = append(u.files, v.(string))
+	}
+
+	for _, v := range d.Get("directories").([]interface{}) {
+		u.directories = append(u.directories, v.(string))
+	}
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := deleteDirectoryUpload(ctx, client, &u)
+	if err != nil {
+		return timeoutError(ctx, "delete", timeout, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func deleteDirectoryUpload(ctx context.Context, client *govmomi.Client, u *directoryUpload) error {
+
+	dc, err := getDatacenter(client, u.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", u.datacenter, err)
+	}
+
+	ds, err := lookupDatastore(client, dc, u.datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", u.datastore, err)
+	}
+
+	fm := object.NewFileManager(client.Client)
+	for _, f := range u.files {
+		_, err := ds.Stat(ctx, f)
+		if err != nil {
+			if isFileNotFoundError(err) {
+				log.Printf("[DEBUG] file %s is already gone", ds.Path(f))
+				continue
+			}
+			return fmt.Errorf("error reading %s: %s", ds.Path(f), err)
+		}
+
+		task, err := fm.DeleteDatastoreFile(ctx, ds.Path(f), dc)
+		if err != nil {
+			return fmt.Errorf("error deleting %s: %s", ds.Path(f), err)
+		}
+
+		_, err = waitForTask(ctx, task, nil)
+		if err != nil {
+			return fmt.Errorf("error deleting %s: %s", ds.Path(f), err)
+		}
+	}
+
+	// Remove the deepest directories first, leaving any that aren't empty.
+	directories := make([]string, len(u.directories))
+	copy(directories, u.directories)
+	sort.Sort(sort.Reverse(sort.StringSlice(directories)))
+
+	for _, directory := range directories {
+		files, err := listDatastoreDirectory(ctx, ds, directory)
+		if err != nil {
+			log.Printf("[DEBUG] unable to list directory %s: %s", ds.Path(directory), err)
+			continue
+		}
+		if len(files) > 0 {
+			log.Printf("[DEBUG] directory %s is not empty and will not be deleted", ds.Path(directory))
+			continue
+		}
+
+		task, err := fm.DeleteDatastoreFile(ctx, ds.Path(directory), dc)
+		if err != nil {
+			return fmt.Errorf("error deleting directory %s: %s", ds.Path(directory), err)
+		}
+
+		_, err = waitForTask(ctx, task, nil)
+		if err != nil {
+			return fmt.Errorf("error deleting directory %s: %s", ds.Path(directory), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package vsphere
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+// Upload of a directory tree
+func TestAccVSphereDirectoryUpload_basic(t *testing.T) {
+	sourceDirectory, err := ioutil.TempDir("", "tf_test_upload")
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+	defer os.RemoveAll(sourceDirectory)
+
+	err = os.MkdirAll(filepath.Join(sourceDirectory, "scripts"), 0755)
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+	for _, name := range []string{"ks.cfg", "scripts/post.sh"} {
+		err = ioutil.WriteFile(filepath.Join(sourceDirectory, name), []byte("# "+name+"\n"), 0644)
+		if err != nil {
+			t.Errorf("error %s", err)
+			return
+		}
+	}
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	resourceName := "vsphere_directory_upload.basic"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVSphereDirectoryUploadDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereDirectoryUploadConfig,
+					datacenter,
+					datastore,
+					sourceDirectory,
+				),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereDirectoryUploadExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "files.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "files.0", "tf_test_upload/ks.cfg"),
+					resource.TestCheckResourceAttr(resourceName, "files.1", "tf_test_upload/scripts/post.sh"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVSphereDirectoryUploadDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*govmomi.Client)
+	finder := find.NewFinder(client.Client, true)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "vsphere_directory_upload" {
+			continue
+		}
+
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+		finder = finder.SetDatacenter(dc)
+
+		ds, err := getDatastore(finder, rs.Primary.Attributes["datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		_, err = ds.Stat(context.TODO(), rs.Primary.Attributes["destination_directory"])
+		if err != nil {
+			switch err.(type) {
+			case object.DatastoreNoSuchFileError, object.DatastoreNoSuchDirectoryError:
+				continue
+			default:
+				return err
+			}
+		}
+		return fmt.Errorf("Directory %s still exists", rs.Primary.Attributes["destination_directory"])
+	}
+
+	return nil
+}
+
+func testAccCheckVSphereDirectoryUploadExists(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Resource not found: %s", n)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("No ID is set")
+		}
+
+		client := testAccProvider.Meta().(*govmomi.Client)
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		finder := find.NewFinder(client.Client, true)
+		finder = finder.SetDatacenter(dc)
+
+		ds, err := getDatastore(finder, rs.Primary.Attributes["datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		for _, f := range []string{"tf_test_upload/ks.cfg", "tf_test_upload/scripts/post.sh"} {
+			_, err = ds.Stat(context.TODO(), f)
+			if err != nil {
+				return fmt.Errorf("File %s does not exist: %s", f, err)
+			}
+		}
+		return nil
+	}
+}
+
+const testAccCheckVSphereDirectoryUploadConfig = `
+resource "vsphere_directory_upload" "basic" {
+	datacenter = "%s"
+	datastore = "%s"
+	source_directory = "%s"
+	destination_directory = "tf_test_upload"
+}
+`
+
+func TestUploadFiles(t *testing.T) {
+	var uploads []fileUpload
+	for i := 0; i < 20; i++ {
+		uploads = append(uploads, fileUpload{
+			localPath:  fmt.Sprintf("/tmp/file%02d", i),
+			remotePath: fmt.Sprintf("dir/file%02d", i),
+		})
+	}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	upload := func(ctx context.Context, u fileUpload) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	uploaded, err := uploadFiles(context.Background(), 4, uploads, upload)
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if len(uploaded) != len(uploads) {
+		t.Fatalf("expected %d uploaded files, got %d", len(uploads), len(uploaded))
+	}
+	if uploaded[0] != "dir/file00" || uploaded[19] != "dir/file19" {
+		t.Fatalf("expected uploaded files in sorted order, got %v", uploaded)
+	}
+	if maxRunning < 2 || maxRunning > 4 {
+		t.Fatalf("expected between 2 and 4 concurrent uploads, got %d", maxRunning)
+	}
+
+	// The first failure stops the remaining uploads
+	count := 0
+	failing := func(ctx context.Context, u fileUpload) error {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		if u.remotePath == "dir/file00" {
+			return errors.New("503 Service Unavailable")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		return nil
+	}
+
+	_, err = uploadFiles(context.Background(), 1, uploads, failing)
+	if err == nil || !strings.Contains(err.Error(), "/tmp/file00") {
+		t.Fatalf("expected error for /tmp/file00, got %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected uploads to stop after the first failure, got %d uploads", count)
+	}
+}
+
+func TestChecksumManifest(t *testing.T) {
+	checksums := map[string]string{
+		"kickstart/scripts/post.sh": "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+		"kickstart/ks.cfg":          "7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730",
+	}
+
+	// sha256sum of the manifest sorted by path
+	manifest := "7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730  kickstart/ks.cfg\n" +
+		"b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  kickstart/scripts/post.sh\n"
+	hash := sha256.Sum256([]byte(manifest))
+	expected := hex.EncodeToString(hash[:])
+
+	if actual := checksumManifest(checksums); actual != expected {
+		t.Fatalf("expected manifest checksum %s, got %s", expected, actual)
+	}
+
+	checksums["kickstart/ks.cfg"] = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if checksumManifest(checksums) == expected {
+		t.Fatal("expected a different manifest checksum after a file changed")
+	}
+}
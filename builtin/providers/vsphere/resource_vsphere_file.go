@@ -1,22 +1,107 @@
 package vsphere
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/progress"
 	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	// progressLogThreshold is the file size from which transfer progress is logged
+	progressLogThreshold = 16 * 1024 * 1024
+
+	// progressLogInterval is how often transfer progress is logged
+	progressLogInterval = 5 * time.Second
+
+	// defaultFileTimeout is the timeout of file operations if none is set
+	defaultFileTimeout = 30 * time.Minute
+
+	// maxRenameAttempts is how many names on_conflict rename tries
+	maxRenameAttempts = 100
+
+	// uploadRetryBackoff is the delay before the first upload retry; it
+	// doubles with every further attempt
+	uploadRetryBackoff = 2 * time.Second
+
+	// fileUploaded and fileChanged are the values of source_status
+	fileUploaded = "uploaded"
+	fileChanged  = "changed"
 )
 
 type file struct {
-	datacenter      string
-	datastore       string
-	sourceFile      string
-	destinationFile string
+	sourceDatacenter   string
+	datacenter         string
+	sourceDatastore    string
+	datastore          string
+	datastoreMember    string
+	datastoreID        string
+	copies             []string
+	host               string
+	vm                 string
+	attachToVM         *fileAttachment
+	verifyBootable     *bootCheck
+	browser            *object.HostDatastoreBrowser
+	sourceFile         string
+	sourceUsed         string
+	destinationFile    string
+	copyFile           bool
+	createDirectories  bool
+	createdDirectories []string
+	uploadRetries      int
+	download           bool
+	force              bool
+	onConflict         string
+	replacing          bool
+	replaced           bool
+	deleteGuard        bool
+	skipIfMatching     bool
+	destinationMatches bool
+	caseConflict       string
+	contentType        string
+	uploadMethod       string
+	freeSpaceMargin    int64
+	maxUploadBandwidth int64
+	cleanupGlob        string
+	checksumType       string
+	expectedFormat     string
+	decompress         string
+	sourceChecksum     string
+	size               int64
+	bytesTransferred   int64
+	uploadDuration     time.Duration
+	lastModified       string
+	copiesModified     []string
 }
 
 func resourceVSphereFile() *schema.Resource {
@@ -25,285 +110,3832 @@ func resourceVSphereFile() *schema.Resource {
 		Read:   resourceVSphereFileRead,
 		Update: resourceVSphereFileUpdate,
 		Delete: resourceVSphereFileDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVSphereFileImport,
+		},
+		SchemaVersion: 1,
+		MigrateState:  resourceVSphereFileMigrateState,
 
 		Schema: map[string]*schema.Schema{
+			"source_datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
 			"datacenter": {
 				Type:     schema.TypeString,
 				Optional: true,
+			},
+
+			"source_datastore": {
+				Type:     schema.TypeString,
+				Optional: true,
 				ForceNew: true,
 			},
 
 			"datastore": {
 				Type:     schema.TypeString,
 				Optional: true,
-				ForceNew: true,
+				Computed: true,
+			},
+
+			"datastores": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"vm_relative_path"},
+				Elem:          &schema.Schema{Type: schema.TypeString},
 			},
 
 			"source_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"content", "source_files"},
+			},
+
+			"source_files": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"source_file", "content", "track_source_changes", "upload_if_newer"},
+			},
+
+			"source_used": {
 				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Computed: true,
+			},
+
+			"content": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"source_file", "source_files"},
+				StateFunc: func(v interface{}) string {
+					switch v.(type) {
+					case string:
+						hash := sha256.Sum256([]byte(v.(string)))
+						return hex.EncodeToString(hash[:])
+					default:
+						return ""
+					}
+				},
 			},
 
 			"destination_file": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+				Computed: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if err := validateDatastorePath(v.(string)); err != nil {
+						errors = append(errors, fmt.Errorf("%q %w", k, err))
+					}
+					if value := datastoreSlashes(strings.TrimSpace(v.(string))); strings.HasSuffix(value, "/") {
+						errors = append(errors, fmt.Errorf(
+							"%q %q ends with a slash, include the file name, e.g. %q", k, v, path.Join(value, "file.iso")))
+					}
+					return
+				},
+			},
+
+			"create_directories": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"created_directories": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"download": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+
+			"force": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"on_conflict": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"force"},
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if value != "overwrite" && value != "skip" && value != "fail" && value != "rename" {
+						errors = append(errors, fmt.Errorf(
+							"only 'overwrite', 'skip', 'fail' and 'rename' are supported values for 'on_conflict'"))
+					}
+					return
+				},
+			},
+
+			"destination_used": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"skip_if_matching_checksum": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"content_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"upload_method": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  soap.DefaultUpload.Method,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if value != "PUT" && value != "POST" {
+						errors = append(errors, fmt.Errorf(
+							"only 'PUT' and 'POST' are supported values for 'upload_method'"))
+					}
+					return
+				},
+			},
+
+			"case_conflict": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "warn",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if value != "warn" && value != "error" {
+						errors = append(errors, fmt.Errorf(
+							"only 'warn' and 'error' are supported values for 'case_conflict'"))
+					}
+					return
+				},
+			},
+
+			"enforce_matching_extension": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if value != "warn" && value != "error" {
+						errors = append(errors, fmt.Errorf(
+							"only 'warn' and 'error' are supported values for 'enforce_matching_extension'"))
+					}
+					return
+				},
+			},
+
+			"free_space_margin": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must not be negative", k))
+					}
+					return
+				},
+			},
+
+			"max_upload_bandwidth": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must not be negative", k))
+					}
+					return
+				},
+			},
+
+			"delete_guard": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"cleanup_glob": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if strings.Contains(value, "/") {
+						errors = append(errors, fmt.Errorf(
+							"%q must not contain '/', it only matches files in the directory of destination_file", k))
+					}
+					if _, err := path.Match(value, ""); err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q is not a valid pattern: %w", k, err))
+					}
+					return
+				},
+			},
+
+			"host": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"vm": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"vm_relative_path": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if err := validateDatastorePath(v.(string)); err != nil {
+						errors = append(errors, fmt.Errorf("%q %w", k, err))
+					}
+					return
+				},
+			},
+
+			"timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "30m",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					duration, err := time.ParseDuration(value)
+					if err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q cannot be parsed as a duration: %w", k, err))
+					}
+					if duration <= 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be greater than zero", k))
+					}
+					return
+				},
+			},
+
+			"upload_retries": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  3,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must not be negative", k))
+					}
+					return
+				},
+			},
+
+			"checksum_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "md5",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if value != "md5" && value != "sha256" {
+						errors = append(errors, fmt.Errorf(
+							"only 'md5' and 'sha256' are supported values for 'checksum_type'"))
+					}
+					return
+				},
+			},
+
+			"source_checksum": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+
+			// Read sets this to "changed" when the file has to be uploaded
+			// again, so that the next plan shows a diff that Update applies
+			// in place. Replacing the resource would upload to its own
+			// destination, which fails without force.
+			"source_status": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  fileUploaded,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(string) != fileUploaded {
+						errors = append(errors, fmt.Errorf(
+							"only '%s' is supported value for '%s'", fileUploaded, k))
+					}
+					return
+				},
+			},
+
+			"decompress": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "none",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if _, ok := decompressExtensions[value]; !ok && value != "none" {
+						errors = append(errors, fmt.Errorf(
+							"only 'none', 'gzip' and 'bzip2' are supported values for 'decompress'"))
+					}
+					return
+				},
+			},
+
+			"expected_format": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					if _, ok := fileFormats[value]; !ok {
+						errors = append(errors, fmt.Errorf(
+							"%q must be one of %s", k, strings.Join(supportedFileFormats(), ", ")))
+					}
+					return
+				},
+			},
+
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"track_source_changes": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"source_file_hash": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"upload_if_newer": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"track_source_changes"},
+			},
+
+			"source_file_mtime": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"last_task_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"datastore_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"datastore_copies": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"datastore_copies_modified": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"datastore_member": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"datastore_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"custom_attributes": {
+				Type:     schema.TypeMap,
+				Optional: true,
+			},
+
+			"create_custom_attributes": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"bytes_transferred": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"upload_duration_seconds": {
+				Type:     schema.TypeFloat,
+				Computed: true,
+			},
+
+			"description": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"vsphere_server": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"allow_unverified_ssl": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"attach_to_vm": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"vm": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"device_key": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"verify_bootable": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"resource_pool": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"network": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"guest_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "otherGuest64",
+						},
+
+						"memory": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  512,
+						},
+
+						"boot_wait": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "30s",
+							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+								value := v.(string)
+								duration, err := time.ParseDuration(value)
+								if err != nil {
+									errors = append(errors, fmt.Errorf(
+										"%q cannot be parsed as a duration: %w", k, err))
+								}
+								if duration <= 0 {
+									errors = append(errors, fmt.Errorf(
+										"%q must be greater than zero", k))
+								}
+								return
+							},
+						},
+
+						"timeout": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "10m",
+							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+								value := v.(string)
+								duration, err := time.ParseDuration(value)
+								if err != nil {
+									errors = append(errors, fmt.Errorf(
+										"%q cannot be parsed as a duration: %w", k, err))
+								}
+								if duration <= 0 {
+									errors = append(errors, fmt.Errorf(
+										"%q must be greater than zero", k))
+								}
+								return
+							},
+						},
+					},
+				},
+			},
+
+			"guest_verify": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"vm": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"user": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"password": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+
+						"path": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"timeout": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "5m",
+							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+								value := v.(string)
+								duration, err := time.ParseDuration(value)
+								if err != nil {
+									errors = append(errors, fmt.Errorf(
+										"%q cannot be parsed as a duration: %w", k, err))
+								}
+								if duration <= 0 {
+									errors = append(errors, fmt.Errorf(
+										"%q must be greater than zero", k))
+								}
+								return
+							},
+						},
+					},
+				},
+			},
+
+			"wait_for_host_visibility": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"host_visibility_timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "5m",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					duration, err := time.ParseDuration(value)
+					if err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q cannot be parsed as a duration: %w", k, err))
+					}
+					if duration <= 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be greater than zero", k))
+					}
+					return
+				},
+			},
+
+			"run_as": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"user": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"password": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+
+			"last_modified": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"download_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"datastore_path": {
+				Type:     schema.TypeString,
+				Computed: true,
 			},
 		},
 	}
 }
 
-func resourceVSphereFileCreate(d *schema.ResourceData, meta interface{}) error {
+func resourceVSphereFileCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_file"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] creating file %s on datastore %s", d.Get("destination_file"), d.Get("datastore"))
+
+	// With vm_relative_path, both are resolved from the vm below. They are
+	// computed then, which rules out ConflictsWith.
+	vmRelativePath := d.Get("vm_relative_path").(string)
+	if vmRelativePath != "" {
+		for _, k := range []string{"datastore", "destination_file"} {
+			if _, ok := d.GetOk(k); ok {
+				return fmt.Errorf("%s cannot be used together with vm_relative_path", k)
+			}
+		}
+	}
+
+	f, err := expandFile(d)
+	if err != nil {
+		return err
+	}
+
+	if v, ok := d.GetOk("source_checksum"); ok {
+		f.sourceChecksum = v.(string)
+	}
+
+	ctx, client, done, err := fileSession(d, meta)
+	if err != nil {
+		return err
+	}
+	defer done()
+	timeout := fileTimeout(d)
+
+	contentFile, err := prepareSourceFile(ctx, d, f)
+	if err != nil {
+		return err
+	}
+	if contentFile != "" {
+		defer os.Remove(contentFile)
+	}
+
+	err = checkSourceExtension(d, f, contentFile)
+	if err != nil {
+		return err
+	}
+
+	if f.download && d.Get("wait_for_host_visibility").(bool) {
+		return fmt.Errorf("wait_for_host_visibility cannot be used together with download")
+	}
+	guestCheck := expandGuestVerify(d.Get("guest_verify"))
+	if f.download && guestCheck != nil {
+		return fmt.Errorf("guest_verify cannot be used together with download")
+	}
+	customAttributes := d.Get("custom_attributes").(map[string]interface{})
+	if f.download && len(customAttributes) > 0 {
+		return fmt.Errorf("custom_attributes cannot be used together with download")
+	}
+	if datastores := d.Get("datastores").([]interface{}); len(datastores) > 0 {
+		// datastore is computed, which rules out ConflictsWith
+		if _, ok := d.GetOk("datastore"); ok {
+			return fmt.Errorf("datastore cannot be used together with datastores")
+		}
+		if f.download {
+			return fmt.Errorf("datastores cannot be used together with download")
+		}
+		if ds := duplicateDatastore(interfacesToStrings(datastores)); ds != "" {
+			return fmt.Errorf("datastore %q is listed more than once in datastores", ds)
+		}
+		d.Set("datastore", f.datastore)
+	}
+
+	if vmRelativePath != "" {
+		if f.download {
+			return fmt.Errorf("vm_relative_path cannot be used together with download")
+		}
+		f.datastore, f.destinationFile, err = vmHomePath(ctx, client, f.datacenter, f.vm, vmRelativePath)
+		if err != nil {
+			return err
+		}
+		d.Set("datastore", f.datastore)
+		d.Set("destination_file", f.destinationFile)
+	}
+
+	configured := f.destinationFile
+	err = createFile(ctx, client, f)
+	if err != nil {
+		return timeoutError(ctx, "create", timeout, err)
+	}
+
+	if f.destinationFile != configured {
+		d.Set("destination_used", f.destinationFile)
+	}
+	d.Set("source_checksum", f.sourceChecksum)
+	d.Set("source_used", f.sourceUsed)
+	d.Set("created_directories", f.createdDirectories)
+	d.Set("datastore_member", f.datastoreMember)
+	setSourceFileHash(d, f)
+	setSourceFileMtime(d, f)
+	if !f.download {
+		d.Set("size", int(f.size))
+		d.Set("last_modified", f.lastModified)
+		setUploadStats(d, f)
+	}
+
+	d.SetId(datastoreObjectID(f.datastoreMember, f.datacenter, f.destinationFile))
+	log.Printf("[INFO] Created file: %s", f.destinationFile)
+
+	if len(f.copies) > 0 {
+		members, err := uploadCopies(ctx, client, f)
+		d.Set("datastore_copies", members)
+		d.Set("datastore_copies_modified", f.copiesModified)
+		if err != nil {
+			return timeoutError(ctx, "create", timeout, err)
+		}
+	}
+
+	if d.Get("wait_for_host_visibility").(bool) {
+		err = waitForHostVisibility(ctx, client, f, hostVisibilityTimeout(d))
+		if err != nil {
+			return timeoutError(ctx, "create", timeout, err)
+		}
+	}
+
+	if guestCheck != nil {
+		err = verifyGuestFile(ctx, client, f.datacenter, guestCheck)
+		if err != nil {
+			return timeoutError(ctx, "create", timeout, err)
+		}
+	}
+
+	if f.attachToVM != nil {
+		err = attachFile(ctx, client, f)
+		if err != nil {
+			return timeoutError(ctx, "create", timeout, err)
+		}
+	}
+
+	err = setCustomAttributes(ctx, client, f.datastoreID, nil, customAttributes, d.Get("create_custom_attributes").(bool))
+	if err != nil {
+		return timeoutError(ctx, "create", timeout, err)
+	}
+
+	return resourceVSphereFileRead(d, meta)
+}
+
+func createFile(ctx context.Context, client *govmomi.Client, f *file) error {
+
+	if f.download && f.copyFile {
+		return fmt.Errorf("download cannot be used together with source_datastore")
+	}
+	if f.download && f.attachToVM != nil {
+		return fmt.Errorf("attach_to_vm cannot be used together with download")
+	}
+	if f.download && f.verifyBootable != nil {
+		return fmt.Errorf("verify_bootable cannot be used together with download")
+	}
+	if f.download && (f.onConflict == "skip" || f.onConflict == "rename") {
+		return fmt.Errorf("on_conflict %q cannot be used together with download", f.onConflict)
+	}
+	if f.decompressing() && (f.download || f.copyFile || isURLSource(f.sourceFile)) {
+		return fmt.Errorf("decompress can only be used to upload a local source_file")
+	}
+	if f.download && isDatastorePath(f.destinationFile) {
+		return fmt.Errorf("destination_file %q must be a local path when download is set", f.destinationFile)
+	}
+	if !f.download && !f.copyFile && isDatastorePath(f.sourceFile) {
+		return fmt.Errorf("source_file %q must be a local path unless download is set", f.sourceFile)
+	}
+	if err := validateDatastorePath(f.destinationFile); err != nil {
+		return fmt.Errorf("destination_file %w", err)
+	}
+	if f.download || f.copyFile {
+		if err := validateDatastorePath(f.sourceFile); err != nil {
+			return fmt.Errorf("source_file %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dc, err := getDatacenterContext(ctx, client, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %w", f.datacenter, err)
+	}
+
+	var ds *object.Datastore
+	if f.download {
+		ds, err = lookupDatastoreContext(ctx, client, dc, f.datastore)
+	} else {
+		ds, err = resolveDatastore(ctx, client, dc, f.datastore)
+	}
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %w", f.datastore, err)
+	}
+	f.datastoreMember = ds.Name()
+	f.datastoreID = ds.Reference().Value
+
+	f.browser, err = datastoreBrowser(ctx, client, dc, ds, f.host)
+	if err != nil {
+		return err
+	}
+
+	if !f.download {
+		err = checkDestinationWritable(ctx, client, ds, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkDestinationNotDirectory(ctx, ds, f)
+	if err != nil {
+		return err
+	}
+
+	if !f.download && f.vm != "" {
+		err = checkDatastoreVisibleToVM(ctx, client, dc, ds, f.vm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.attachToVM != nil {
+		// Fail before uploading rather than after
+		_, _, _, err = findCdrom(ctx, client, dc, f.attachToVM)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.skipIfMatching && !f.download && !f.copyFile && !isURLSource(f.sourceFile) && !f.decompressing() {
+		f.destinationMatches = destinationMatchesSource(ctx, client, dc, ds, f)
+	}
+
+	if !f.replacing && !f.destinationMatches && (f.onConflict == "skip" || f.onConflict == "rename") {
+		info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+		if err == nil && f.onConflict == "skip" {
+			log.Printf("[INFO] %s already exists, keeping it as on_conflict is skip", ds.Path(f.destinationFile))
+			fi := info.GetFileInfo()
+			f.size = fi.FileSize
+			f.lastModified = fileModification(fi)
+			return nil
+		}
+		if err == nil {
+			existing := ds.Path(f.destinationFile)
+			f.destinationFile, err = unusedDestination(ctx, ds, f.browser, f.destinationFile, time.Now())
+			if err != nil {
+				return err
+			}
+			log.Printf("[INFO] %s already exists, uploading to %s as on_conflict is rename", existing, f.destinationFile)
+		} else if !isFileNotFoundError(err) {
+			return fmt.Errorf("error reading destination_file %s: %w", ds.Path(f.destinationFile), err)
+		}
+	}
+
+	// A destination that already matches isn't overwritten
+	if !f.force && !f.destinationMatches {
+		err = checkDestinationAbsent(ctx, ds, f)
+		if err != nil {
+			return err
+		}
+	} else if !f.replacing && !f.destinationMatches {
+		logOverwrite(ctx, ds, f)
+	}
+
+	if !f.download && !f.destinationMatches {
+		err = checkCaseConflict(ctx, ds, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = transferFile(ctx, client, dc, ds, f)
+	if err != nil || f.verifyBootable == nil {
+		return err
+	}
+
+	err = verifyBootable(ctx, client, dc, ds, f)
+	if err != nil {
+		// Like a checksum mismatch, a file that fails the check isn't kept
+		log.Printf("[DEBUG] removing %s after failed boot check", ds.Path(f.destinationFile))
+		if task, err := object.NewFileManager(client.Client).DeleteDatastoreFile(ctx, ds.Path(f.destinationFile), dc); err == nil {
+			task.Wait(ctx)
+		}
+		return err
+	}
+	return nil
+}
+
+// transferFile copies, downloads or uploads the file f once createFile has
+// checked its destination.
+func transferFile(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, f *file) error {
+	if f.copyFile {
+		// Copying file from within vSphere
+		// The source and destination may be in different datacenters,
+		// which CopyDatastoreFile takes separately.
+		sourceDatacenter := f.sourceDatacenter
+		if sourceDatacenter == "" {
+			sourceDatacenter = f.datacenter
+		}
+		sourceDc, err := getDatacenterContext(ctx, client, sourceDatacenter)
+		if err != nil {
+			return fmt.Errorf("error finding source_datacenter %q: %w", sourceDatacenter, err)
+		}
+		sourceDs, err := lookupDatastoreContext(ctx, client, sourceDc, f.sourceDatastore)
+		if err != nil {
+			return fmt.Errorf("error finding source_datastore %q: %w", f.sourceDatastore, err)
+		}
+
+		fm := object.NewFileManager(client.Client)
+		if f.createDirectories {
+			f.createdDirectories, err = createDirectory(ctx, fm, ds, dc, f.destinationFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		task, err := fm.CopyDatastoreFile(ctx, sourceDs.Path(f.sourceFile), sourceDc, ds.Path(f.destinationFile), dc, f.force)
+		if err != nil {
+			return fmt.Errorf("error copying %s to %s: %w", sourceDs.Path(f.sourceFile), ds.Path(f.destinationFile), err)
+		}
+
+		_, err = waitForTask(ctx, task, nil)
+		if err != nil {
+			return fmt.Errorf("error copying %s to %s: %w", sourceDs.Path(f.sourceFile), ds.Path(f.destinationFile), err)
+		}
+
+		info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+		if err != nil {
+			return fmt.Errorf("error reading copied file %s: %w", ds.Path(f.destinationFile), err)
+		}
+		fi := info.GetFileInfo()
+		f.size = fi.FileSize
+		f.lastModified = fileModification(fi)
+		return nil
+	}
+
+	if f.download {
+		dsurl, err := ds.URL(ctx, dc, f.sourceFile)
+		if err != nil {
+			return err
+		}
+
+		p := soap.DefaultDownload
+		p.Progress = newProgressLogger(ctx, f.sourceFile)
+		err = downloadFileContext(ctx, client, f.destinationFile, dsurl, &p)
+		if err != nil {
+			return fmt.Errorf("error downloading %s to %s: %w", ds.Path(f.sourceFile), f.destinationFile, err)
+		}
+		return verifyChecksum(f, f.destinationFile)
+	}
+
+	if isURLSource(f.sourceFile) {
+		return uploadFromURL(ctx, client, ds, dc, f)
+	}
+
+	if f.decompressing() {
+		return uploadDecompressed(ctx, client, ds, dc, f)
+	}
+
+	err := verifyFileFormat(f.sourceFile, f.expectedFormat)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChecksum(f, f.sourceFile)
+	if err != nil {
+		return err
+	}
+
+	local, err := os.Stat(f.sourceFile)
+	if err != nil {
+		return fmt.Errorf("error reading source_file: %w", err)
+	}
+
+	if f.destinationMatches {
+		log.Printf("[INFO] %s already has the content of %s, skipping the upload", ds.Path(f.destinationFile), f.sourceFile)
+		return verifyUpload(ctx, ds, f, local.Size())
+	}
+
+	err = checkFreeSpace(ctx, client, ds, f, local.Size())
+	if err != nil {
+		return err
+	}
+
+	if f.createDirectories {
+		f.createdDirectories, err = createDirectory(ctx, object.NewFileManager(client.Client), ds, dc, f.destinationFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	dsurl, err := ds.URL(ctx, dc, f.destinationFile)
+	if err != nil {
+		return err
+	}
+
+	p := newUploadParams(ctx, f, local.Size())
+	start := time.Now()
+	p.ContentLength = local.Size()
+	err = uploadWithRetry(ctx, f.uploadRetries, func() error {
+		fh, err := os.Open(f.sourceFile)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		return uploadContext(ctx, client, throttle(ctx, fh, f.maxUploadBandwidth), dsurl, &p)
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading %s to %s: %w", f.sourceFile, ds.Path(f.destinationFile), err)
+	}
+	f.recordUpload(local.Size(), time.Since(start))
+
+	return verifyUpload(ctx, ds, f, local.Size())
+}
+
+// newUploadParams returns the parameters for uploading the source of f, which
+// is size bytes large.
+func newUploadParams(ctx context.Context, f *file, size int64) soap.Upload {
+	p := soap.DefaultUpload
+	p.Type = f.contentType
+	if p.Type == "" {
+		p.Type = detectContentType(f.destinationFile)
+	}
+	if f.uploadMethod != "" {
+		p.Method = f.uploadMethod
+	}
+	log.Printf("[DEBUG] uploading %s with %s and content type %s", redactSourceURL(f.sourceFile), p.Method, p.Type)
+	if size >= progressLogThreshold {
+		p.Progress = newProgressLogger(ctx, redactSourceURL(f.sourceFile))
+	}
+	return p
+}
+
+// recordUpload records the size and duration of a completed upload, which
+// are exported as bytes_transferred and upload_duration_seconds. The
+// duration includes retries.
+func (f *file) recordUpload(size int64, duration time.Duration) {
+	f.bytesTransferred = size
+	f.uploadDuration = duration
+	log.Printf("[INFO] uploaded %d bytes to %s in %s", size, f.destinationFile, duration)
+}
+
+// setUploadStats stores the statistics of the last upload of f in state.
+func setUploadStats(d *schema.ResourceData, f *file) {
+	d.Set("bytes_transferred", int(f.bytesTransferred))
+	d.Set("upload_duration_seconds", f.uploadDuration.Seconds())
+}
+
+// uploadFromURL streams the source of f from an http(s) URL to the datastore,
+// without staging it on the Terraform host. The checksum is computed while
+// the file is streamed, and checked once the upload is complete.
+func uploadFromURL(ctx context.Context, client *govmomi.Client, ds *object.Datastore, dc *object.Datacenter, f *file) error {
+	var err error
+	if f.createDirectories {
+		f.createdDirectories, err = createDirectory(ctx, object.NewFileManager(client.Client), ds, dc, f.destinationFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	dsurl, err := ds.URL(ctx, dc, f.destinationFile)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	var checksum string
+	start := time.Now()
+	err = uploadWithRetry(ctx, f.uploadRetries, func() error {
+		res, err := openSourceURL(ctx, f.sourceFile)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		size = res.ContentLength
+		if err := checkFreeSpace(ctx, client, ds, f, size); err != nil {
+			return err
+		}
+
+		h, err := newChecksumHash(f.checksumType)
+		if err != nil {
+			return err
+		}
+
+		p := newUploadParams(ctx, f, size)
+		p.ContentLength = size
+		err = uploadContext(ctx, client, io.TeeReader(throttle(ctx, res.Body, f.maxUploadBandwidth), h), dsurl, &p)
+		if err != nil {
+			return err
+		}
+		checksum = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading %s to %s: %w", redactSourceURL(f.sourceFile), ds.Path(f.destinationFile), err)
+	}
+	f.recordUpload(size, time.Since(start))
+
+	if f.sourceChecksum != "" && !strings.EqualFold(f.sourceChecksum, checksum) {
+		log.Printf("[DEBUG] removing %s after checksum mismatch", ds.Path(f.destinationFile))
+		if task, err := object.NewFileManager(client.Client).DeleteDatastoreFile(ctx, ds.Path(f.destinationFile), dc); err == nil {
+			task.Wait(ctx)
+		}
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s",
+			f.checksumType, redactSourceURL(f.sourceFile), f.sourceChecksum, checksum)
+	}
+	f.sourceChecksum = checksum
+
+	return verifyUpload(ctx, ds, f, size)
+}
+
+// uploadDecompressed uploads the decompressed content of a local source file.
+// Uploads need the size in advance, so the file is decompressed once to
+// check it and compute its size and checksum, and then again while it is
+// uploaded.
+func uploadDecompressed(ctx context.Context, client *govmomi.Client, ds *object.Datastore, dc *object.Datacenter, f *file) error {
+	size, checksum, err := scanDecompressed(f.sourceFile, f.decompress, f.checksumType)
+	if err != nil {
+		return err
+	}
+	if f.sourceChecksum != "" && !strings.EqualFold(f.sourceChecksum, checksum) {
+		return fmt.Errorf("%s checksum mismatch for decompressed %s: expected %s, got %s",
+			f.checksumType, f.sourceFile, f.sourceChecksum, checksum)
+	}
+	f.sourceChecksum = checksum
+
+	err = verifyDecompressedFileFormat(f.sourceFile, f.decompress, f.expectedFormat)
+	if err != nil {
+		return err
+	}
+
+	err = checkFreeSpace(ctx, client, ds, f, size)
+	if err != nil {
+		return err
+	}
+
+	if f.createDirectories {
+		f.createdDirectories, err = createDirectory(ctx, object.NewFileManager(client.Client), ds, dc, f.destinationFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	dsurl, err := ds.URL(ctx, dc, f.destinationFile)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = uploadWithRetry(ctx, f.uploadRetries, func() error {
+		r, err := openDecompressed(f.sourceFile, f.decompress)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		p := newUploadParams(ctx, f, size)
+		p.ContentLength = size
+		return uploadContext(ctx, client, throttle(ctx, r, f.maxUploadBandwidth), dsurl, &p)
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading decompressed %s to %s: %w", f.sourceFile, ds.Path(f.destinationFile), err)
+	}
+	f.recordUpload(size, time.Since(start))
+
+	return verifyUpload(ctx, ds, f, size)
+}
+
+// decompressing returns whether the source of f is decompressed on upload.
+func (f *file) decompressing() bool {
+	return f.decompress != "" && f.decompress != "none"
+}
+
+// openSourceURL starts downloading a source_file from an http(s) URL.
+// Redirects are followed. Uploads to a datastore need the size of the file in
+// advance, so responses without a Content-Length are rejected. The download
+// stops when ctx is done.
+func openSourceURL(ctx context.Context, sourceURL string) (*http.Response, error) {
+	res, err := ctxhttp.Get(ctx, nil, sourceURL)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			uerr.URL = redactSourceURL(uerr.URL)
+		}
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		// Starting with the status line lets 5xx responses be retried
+		return nil, fmt.Errorf("%s from source_file %s", res.Status, redactSourceURL(sourceURL))
+	}
+
+	if res.ContentLength < 0 {
+		res.Body.Close()
+		return nil, fmt.Errorf("source_file %s has no Content-Length, which is needed to upload it to a datastore", redactSourceURL(sourceURL))
+	}
+	return res, nil
+}
+
+// isURLSource reports whether a source_file is an http(s) URL to stream from.
+func isURLSource(sourceFile string) bool {
+	return strings.HasPrefix(sourceFile, "http://") || strings.HasPrefix(sourceFile, "https://")
+}
+
+// redactSourceURL hides the password of a source_file URL, so that it doesn't
+// end up in logs and error messages. Other sources are returned as is.
+func redactSourceURL(sourceFile string) string {
+	if !isURLSource(sourceFile) {
+		return sourceFile
+	}
+
+	u, err := url.Parse(sourceFile)
+	if err != nil || u.User == nil {
+		return sourceFile
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// newProgressLogger returns a progress.Sinker that logs the progress of a
+// transfer every progressLogInterval. The sender blocks on every report, so
+// the logging goroutine receives them until the transfer is done and the
+// channel is closed, and only stops logging once ctx is cancelled.
+func newProgressLogger(ctx context.Context, name string) progress.Sinker {
+	return progress.SinkFunc(func() chan<- progress.Report {
+		ch := make(chan progress.Report)
+		go func() {
+			var last time.Time
+			var done bool
+			for r := range ch {
+				if ctx.Err() != nil {
+					continue
+				}
+				if r.Error() != nil {
+					log.Printf("[WARN] transfer of %s failed at %.0f%%: %s", name, r.Percentage(), r.Error())
+					continue
+				}
+				pct := r.Percentage()
+				if done || (pct < 100 && time.Since(last) < progressLogInterval) {
+					continue
+				}
+				log.Printf("[INFO] transfer of %s: %.0f%% (%s)", name, pct, r.Detail())
+				last = time.Now()
+				done = pct >= 100
+			}
+		}()
+		return ch
+	})
+}
+
+// createDirectory creates the parent directories of a datastore file and
+// returns the directories that did not exist before, top-down. It is a no-op
+// when the directories already exist.
+func createDirectory(ctx context.Context, fm *object.FileManager, ds *object.Datastore, dc *object.Datacenter, destinationFile string) ([]string, error) {
+	return makeDirectory(ctx, fm, ds, dc, path.Dir(strings.TrimLeft(destinationFile, "/")))
+}
+
+// makeDirectory creates a datastore directory including its parents and
+// returns the directories that did not exist before, top-down. Resources
+// creating the same directory are serialized, so only the first one makes the
+// API calls and reports the directories as created.
+func makeDirectory(ctx context.Context, fm *object.FileManager, ds *object.Datastore, dc *object.Datacenter, directory string) ([]string, error) {
+	directory = strings.Trim(directory, "/")
+	if directory == "." || directory == "" {
+		return nil, nil
+	}
+
+	key := directoryLockKey(ds, directory)
+	vsphereMutexKV.Lock(key)
+	defer vsphereMutexKV.Unlock(key)
+
+	var created []string
+	var workingPath string
+	for _, pathPart := range strings.Split(directory, "/") {
+		workingPath = path.Join(workingPath, pathPart)
+		if len(created) > 0 {
+			created = append(created, workingPath)
+			continue
+		}
+
+		_, err := ds.Stat(ctx, workingPath)
+		if err != nil {
+			if !isFileNotFoundError(err) {
+				return nil, fmt.Errorf("error reading directory %s: %w", ds.Path(workingPath), err)
+			}
+			created = append(created, workingPath)
+		}
+	}
+
+	if len(created) == 0 {
+		log.Printf("[DEBUG] directory %s already exists", ds.Path(directory))
+		return nil, nil
+	}
+
+	err := fm.MakeDirectory(ctx, ds.Path(directory), dc, true)
+	if err != nil {
+		if soap.IsSoapFault(err) {
+			if _, ok := soap.ToSoapFault(err).VimFault().(types.FileAlreadyExists); ok {
+				log.Printf("[DEBUG] directory %s already exists", ds.Path(directory))
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("error creating directory %s: %w", ds.Path(directory), err)
+	}
+	return created, nil
+}
+
+// directoryLockKey identifies a datastore directory across datacenters, for
+// serializing its creation.
+func directoryLockKey(ds *object.Datastore, directory string) string {
+	return ds.Reference().Value + ":" + directory
+}
+
+// removeDirectories removes the given datastore directories bottom-up,
+// stopping at the first one that is not empty.
+func removeDirectories(ctx context.Context, fm *object.FileManager, ds *object.Datastore, dc *object.Datacenter, directories []string) {
+	for i := len(directories) - 1; i >= 0; i-- {
+		directory := directories[i]
+
+		files, err := listDatastoreDirectory(ctx, ds, directory)
+		if err != nil {
+			log.Printf("[DEBUG] unable to list directory %s: %s", ds.Path(directory), err)
+			continue
+		}
+		if len(files) > 0 {
+			log.Printf("[DEBUG] directory %s is not empty and will not be deleted", ds.Path(directory))
+			return
+		}
+
+		task, err := fm.DeleteDatastoreFile(ctx, ds.Path(directory), dc)
+		if err == nil {
+			_, err = waitForTask(ctx, task, nil)
+		}
+		if err != nil {
+			log.Printf("[DEBUG] unable to delete directory %s: %s", ds.Path(directory), err)
+			continue
+		}
+		log.Printf("[INFO] Deleted directory: %s", ds.Path(directory))
+	}
+}
+
+// listDatastoreDirectory returns the entries of a datastore directory
+func listDatastoreDirectory(ctx context.Context, ds *object.Datastore, directory string) ([]types.BaseFileInfo, error) {
+	b, err := ds.Browser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return searchDatastoreDirectory(ctx, ds, b, directory)
+}
+
+// searchDatastoreDirectory lists the files in a datastore directory whose
+// names match any of patterns, or all files if no pattern is given.
+func searchDatastoreDirectory(ctx context.Context, ds *object.Datastore, b *object.HostDatastoreBrowser, directory string, patterns ...string) ([]types.BaseFileInfo, error) {
+	spec := types.HostDatastoreBrowserSearchSpec{
+		MatchPattern: patterns,
+	}
+	task, err := b.SearchDatastore(ctx, ds.Path(directory), &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := waitForTask(ctx, task, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := info.Result.(types.HostDatastoreBrowserSearchResults)
+	return res.File, nil
+}
+
+// datastoreBrowser returns the browser used to search ds. If host is set, the
+// browser of that host is returned, rather than whichever host vCenter picks,
+// which in a stretched cluster may not see the datastore.
+func datastoreBrowser(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, host string) (*object.HostDatastoreBrowser, error) {
+	if host == "" {
+		b, err := ds.Browser(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error finding datastore browser of %s: %w", ds.Name(), err)
+		}
+		return b, nil
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	h, err := finder.HostSystem(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("error finding host %s: %w", host, err)
+	}
+
+	var mh mo.HostSystem
+	err = h.Properties(ctx, h.Reference(), []string{"datastoreBrowser"}, &mh)
+	if err != nil {
+		return nil, fmt.Errorf("error finding datastore browser of host %s: %w", host, err)
+	}
+
+	log.Printf("[DEBUG] using datastore browser of host %s", host)
+	return object.NewHostDatastoreBrowser(client.Client, mh.DatastoreBrowser), nil
+}
+
+// statDatastoreFile works like Datastore.Stat, but searches with the browser
+// b instead of the one of the datastore.
+func statDatastoreFile(ctx context.Context, ds *object.Datastore, b *object.HostDatastoreBrowser, file string) (types.BaseFileInfo, error) {
+	spec := types.HostDatastoreBrowserSearchSpec{
+		Details: &types.FileQueryFlags{
+			FileType:     true,
+			FileSize:     true,
+			Modification: true,
+			FileOwner:    types.NewBool(true),
+		},
+		MatchPattern: []string{path.Base(file)},
+	}
+
+	dsPath := ds.Path(path.Dir(file))
+	task, err := b.SearchDatastore(ctx, dsPath, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing directory fails the task with FileNotFound, which
+	// isFileNotFoundError recognizes.
+	info, err := waitForTask(ctx, task, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := info.Result.(types.HostDatastoreBrowserSearchResults)
+	if len(res.File) == 0 {
+		return nil, datastoreFileNotFoundError{ds.Path(file)}
+	}
+	return res.File[0], nil
+}
+
+// checkDestinationWritable checks, before anything is written, that the
+// datastore can be written to, and that the directory of the destination
+// exists unless it will be created.
+func checkDestinationWritable(ctx context.Context, client *govmomi.Client, ds *object.Datastore, f *file) error {
+	var mds mo.Datastore
+	collector := property.DefaultCollector(client.Client)
+	err := collector.RetrieveOne(ctx, ds.Reference(), []string{"summary", "host"}, &mds)
+	if err != nil {
+		return fmt.Errorf("error reading datastore %s: %w", ds.Name(), err)
+	}
+
+	err = datastoreWriteError(mds)
+	if err != nil {
+		return err
+	}
+
+	directory := path.Dir(f.destinationFile)
+	if f.createDirectories || directory == "." || directory == "/" {
+		return nil
+	}
+
+	_, err = statDatastoreFile(ctx, ds, f.browser, directory)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			return fmt.Errorf("directory %s of destination_file does not exist, set create_directories to create it", ds.Path(directory))
+		}
+		return fmt.Errorf("error reading directory %s: %w", ds.Path(directory), err)
+	}
+	return nil
+}
+
+// datastoreWriteError returns why files can't be written to the datastore
+// mds, or nil if they can: it must be accessible, not in maintenance mode,
+// and mounted read-write on at least one host that can access it. Uploading
+// otherwise fails midway with a permission error that doesn't say why.
+func datastoreWriteError(mds mo.Datastore) error {
+	name := mds.Summary.Name
+	if !mds.Summary.Accessible {
+		return fmt.Errorf("datastore %s is not accessible", name)
+	}
+
+	mode := mds.Summary.MaintenanceMode
+	if mode != "" && mode != string(types.DatastoreSummaryMaintenanceModeStateNormal) {
+		return fmt.Errorf("datastore %s is in maintenance mode (%s), take it out of maintenance mode or use another datastore", name, mode)
+	}
+
+	if len(mds.Host) == 0 {
+		return nil
+	}
+	for _, h := range mds.Host {
+		accessible := h.MountInfo.Accessible == nil || *h.MountInfo.Accessible
+		if accessible && h.MountInfo.AccessMode == string(types.HostMountModeReadWrite) {
+			return nil
+		}
+	}
+	return fmt.Errorf("datastore %s is read-only on all hosts that can access it, use a writable datastore", name)
+}
+
+// resolveDatastore finds the datastore name in dc. If name is a datastore
+// cluster instead, the accessible member with the most free space is
+// returned, so that a file can be placed on the cluster as a whole.
+func resolveDatastore(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, name string) (*object.Datastore, error) {
+	ds, err := lookupDatastoreContext(ctx, client, dc, name)
+	if !isDatastoreNotFoundError(err) {
+		return ds, err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	pod, perr := finder.DatastoreCluster(ctx, inventoryPattern(name))
+	if perr != nil {
+		// Not a datastore cluster either, report the datastore lookup
+		return nil, err
+	}
+
+	children, err := pod.Children(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *object.Datastore
+	var bestFree int64
+	for _, child := range children {
+		member, ok := child.(*object.Datastore)
+		if !ok {
+			continue
+		}
+
+		summary, err := datastoreSummary(ctx, client, member)
+		if err != nil {
+			return nil, err
+		}
+		if !summary.Accessible || (summary.MaintenanceMode != "" && summary.MaintenanceMode != string(types.DatastoreSummaryMaintenanceModeStateNormal)) {
+			log.Printf("[DEBUG] skipping datastore %s of cluster %s, it is not available", summary.Name, name)
+			continue
+		}
+
+		if best == nil || summary.FreeSpace > bestFree {
+			member.InventoryPath = path.Join(pod.InventoryPath, summary.Name)
+			best = member
+			bestFree = summary.FreeSpace
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("datastore cluster %s has no accessible datastore", name)
+	}
+
+	log.Printf("[INFO] placing file on datastore %s of cluster %s, with %d bytes free", best.Name(), name, bestFree)
+	return best, nil
+}
+
+// vmHomePath returns the datastore and path of the file rel relative to the
+// home directory of the virtual machine vmName, the directory holding its
+// .vmx file.
+func vmHomePath(ctx context.Context, client *govmomi.Client, datacenter, vmName, rel string) (string, string, error) {
+	if vmName == "" {
+		return "", "", fmt.Errorf("vm_relative_path requires vm to be set")
+	}
+
+	dc, err := getDatacenterContext(ctx, client, datacenter)
+	if err != nil {
+		return "", "", fmt.Errorf("error finding datacenter %q: %w", datacenter, err)
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	vm, err := finder.VirtualMachine(ctx, vmName)
+	if err != nil {
+		return "", "", fmt.Errorf("error finding vm %s: %w", vmName, err)
+	}
+
+	var mvm mo.VirtualMachine
+	err = vm.Properties(ctx, vm.Reference(), []string{"config.files.vmPathName"}, &mvm)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading home directory of vm %s: %w", vmName, err)
+	}
+	if mvm.Config == nil {
+		return "", "", fmt.Errorf("vm %s has no configuration to find its home directory in", vmName)
+	}
+
+	datastore, dir, err := splitVMPathName(mvm.Config.Files.VmPathName)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading home directory of vm %s: %w", vmName, err)
+	}
+
+	p := path.Join(dir, normalizeDatastorePath(rel))
+	log.Printf("[DEBUG] placing %s in the home directory of vm %s: [%s] %s", rel, vmName, datastore, p)
+	return datastore, p, nil
+}
+
+// splitVMPathName splits the datastore path of a .vmx file,
+// "[datastore] directory/name.vmx", into the datastore and the directory.
+func splitVMPathName(vmPathName string) (string, string, error) {
+	end := strings.Index(vmPathName, "]")
+	if !strings.HasPrefix(vmPathName, "[") || end < 2 {
+		return "", "", fmt.Errorf("invalid datastore path %q", vmPathName)
+	}
+	return vmPathName[1:end], path.Dir(normalizeDatastorePath(vmPathName)), nil
+}
+
+// checkDatastoreVisibleToVM returns an error if ds is not mounted on the host
+// the virtual machine vmName runs on, so a file uploaded to it would not be
+// reachable from the virtual machine.
+func checkDatastoreVisibleToVM(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, vmName string) error {
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	vm, err := finder.VirtualMachine(ctx, vmName)
+	if err != nil {
+		return fmt.Errorf("error finding vm %s: %w", vmName, err)
+	}
+
+	host, err := vm.HostSystem(ctx)
+	if err != nil {
+		return fmt.Errorf("error finding host of vm %s: %w", vmName, err)
+	}
+	hostName, err := host.Name(ctx)
+	if err != nil {
+		return fmt.Errorf("error finding host of vm %s: %w", vmName, err)
+	}
+
+	var mds mo.Datastore
+	collector := property.DefaultCollector(client.Client)
+	err = collector.RetrieveOne(ctx, ds.Reference(), []string{"host"}, &mds)
+	if err != nil {
+		return fmt.Errorf("error reading datastore %s: %w", ds.Name(), err)
+	}
+
+	for _, h := range mds.Host {
+		if h.Key != host.Reference() {
+			continue
+		}
+		if h.MountInfo.Accessible != nil && !*h.MountInfo.Accessible {
+			return fmt.Errorf("datastore %s is not accessible from host %s of vm %s", ds.Name(), hostName, vmName)
+		}
+		return nil
+	}
+	return fmt.Errorf("datastore %s is not mounted on host %s of vm %s", ds.Name(), hostName, vmName)
+}
+
+// checkDestinationAbsent returns an error if the destination of a file already
+// exists, so it isn't overwritten by accident.
+func checkDestinationAbsent(ctx context.Context, ds *object.Datastore, f *file) error {
+	if f.download {
+		_, err := os.Stat(f.destinationFile)
+		if err == nil {
+			return fmt.Errorf("destination_file %s already exists, set force or on_conflict to overwrite it", f.destinationFile)
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("error reading destination_file: %w", err)
+		}
+		return nil
+	}
+
+	_, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err == nil {
+		return fmt.Errorf("destination_file %s already exists, set force or on_conflict to overwrite it", ds.Path(f.destinationFile))
+	}
+	if !isFileNotFoundError(err) {
+		return fmt.Errorf("error reading destination_file %s: %w", ds.Path(f.destinationFile), err)
+	}
+	return nil
+}
+
+// unusedDestination returns p with the time now added to its base name, such
+// as iso/ubuntu-20170102T150405Z.iso, and a counter if that exists as well.
+func unusedDestination(ctx context.Context, ds *object.Datastore, b *object.HostDatastoreBrowser, p string, now time.Time) (string, error) {
+	for n := 0; n < maxRenameAttempts; n++ {
+		candidate := renamedDestination(p, now, n)
+		_, err := statDatastoreFile(ctx, ds, b, candidate)
+		if isFileNotFoundError(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading %s: %w", ds.Path(candidate), err)
+		}
+	}
+	return "", fmt.Errorf("no unused name for %s after %d attempts", ds.Path(p), maxRenameAttempts)
+}
+
+// renamedDestination returns p with the UTC time now, and n unless it is 0,
+// added to its base name before the extension.
+func renamedDestination(p string, now time.Time, n int) string {
+	suffix := "-" + now.UTC().Format("20060102T150405Z")
+	if n > 0 {
+		suffix += fmt.Sprintf("-%d", n)
+	}
+
+	ext := path.Ext(p)
+	if ext == path.Base(p) {
+		// A name like .vimrc has no extension
+		ext = ""
+	}
+	return strings.TrimSuffix(p, ext) + suffix + ext
+}
+
+// destinationMatchesSource returns whether the destination of f already has
+// the content of its local source file, according to the digest the
+// datastore HTTP frontend returns for a HEAD request. Backends that don't
+// return a digest, and any failure to get one, are treated as a mismatch so
+// the file is uploaded as usual.
+func destinationMatchesSource(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, f *file) bool {
+	checksumType, remote, err := datastoreFileDigest(ctx, client, dc, ds, f.destinationFile)
+	if err != nil {
+		log.Printf("[DEBUG] unable to read the digest of %s: %s", ds.Path(f.destinationFile), err)
+		return false
+	}
+	if remote == "" {
+		log.Printf("[DEBUG] the datastore returned no digest for %s, uploading it", ds.Path(f.destinationFile))
+		return false
+	}
+
+	local, err := fileChecksum(f.sourceFile, checksumType)
+	if err != nil {
+		log.Printf("[DEBUG] unable to compute the %s checksum of %s: %s", checksumType, f.sourceFile, err)
+		return false
+	}
+
+	if local != remote {
+		log.Printf("[DEBUG] %s checksum of %s is %s, %s has %s", checksumType, f.sourceFile, local, ds.Path(f.destinationFile), remote)
+		return false
+	}
+	return true
+}
+
+// datastoreFileDigest returns the checksum type and hex encoded digest that
+// the datastore HTTP service reports for the file p in ds, or empty strings
+// if it reports none.
+func datastoreFileDigest(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, p string) (string, string, error) {
+	dsurl, err := ds.URL(ctx, dc, p)
+	if err != nil {
+		return "", "", fmt.Errorf("error building the URL of %s: %w", ds.Path(p), err)
+	}
+
+	req, err := http.NewRequest("HEAD", dsurl.String(), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("error building the request for %s: %w", ds.Path(p), err)
+	}
+
+	res, err := ctxhttp.Do(ctx, &client.Client.Client.Client, req)
+	if err != nil {
+		return "", "", err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("HEAD %s: %s", ds.Path(p), res.Status)
+	}
+
+	checksumType, digest := remoteDigest(res.Header)
+	return checksumType, digest, nil
+}
+
+// remoteDigest returns the checksum type and hex encoded digest of a file
+// from the headers of an HTTP response, or empty strings if there is none. A
+// Digest or Content-MD5 header is preferred; a strong ETag is only used if it
+// looks like a plain md5 or sha256 digest, since it's often just a version
+// tag.
+func remoteDigest(h http.Header) (string, string) {
+	for _, v := range strings.Split(h.Get("Digest"), ",") {
+		i := strings.Index(v, "=")
+		if i < 0 {
+			continue
+		}
+		var checksumType string
+		switch strings.ToLower(strings.TrimSpace(v[:i])) {
+		case "sha-256":
+			checksumType = "sha256"
+		case "md5":
+			checksumType = "md5"
+		default:
+			continue
+		}
+		if b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v[i+1:])); err == nil {
+			return checksumType, hex.EncodeToString(b)
+		}
+	}
+
+	if v := h.Get("Content-MD5"); v != "" {
+		if b, err := base64.StdEncoding.DecodeString(v); err == nil && len(b) == md5.Size {
+			return "md5", hex.EncodeToString(b)
+		}
+	}
+
+	etag := h.Get("ETag")
+	if strings.HasPrefix(etag, "W/") {
+		return "", ""
+	}
+	etag = strings.Trim(etag, `"`)
+	if _, err := hex.DecodeString(etag); err == nil {
+		switch len(etag) {
+		case 2 * md5.Size:
+			return "md5", strings.ToLower(etag)
+		case 2 * sha256.Size:
+			return "sha256", strings.ToLower(etag)
+		}
+	}
+	return "", ""
+}
+
+// checkDestinationNotDirectory returns an error if the destination of f is an
+// existing directory, which uploading or downloading would fail on with a
+// less helpful error. Failing to read the destination is left to the
+// transfer.
+func checkDestinationNotDirectory(ctx context.Context, ds *object.Datastore, f *file) error {
+	if f.download {
+		fi, err := os.Stat(f.destinationFile)
+		if err == nil && fi.IsDir() {
+			return fmt.Errorf("destination_file %s is a directory, include the file name in it", f.destinationFile)
+		}
+		return nil
+	}
+
+	info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err == nil && isDatastoreDirectory(info) {
+		return fmt.Errorf("destination_file %s is a directory, include the file name in it", ds.Path(f.destinationFile))
+	}
+	return nil
+}
+
+// isDatastoreDirectory returns whether a datastore browser result is a
+// directory.
+func isDatastoreDirectory(info types.BaseFileInfo) bool {
+	_, ok := info.(*types.FolderFileInfo)
+	return ok
+}
+
+// logOverwrite logs a warning if the destination of a file already exists and
+// is about to be overwritten because force is set. Failing to read the
+// destination is left to the transfer.
+func logOverwrite(ctx context.Context, ds *object.Datastore, f *file) {
+	if f.download {
+		fi, err := os.Stat(f.destinationFile)
+		if err == nil {
+			log.Printf("[WARN] overwriting existing destination_file %s (%d bytes)", f.destinationFile, fi.Size())
+		}
+		return
+	}
+
+	info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err == nil {
+		log.Printf("[WARN] overwriting existing destination_file %s (%d bytes)", ds.Path(f.destinationFile), info.GetFileInfo().FileSize)
+	}
+}
+
+// checkCaseConflict looks for a file next to the destination whose name only
+// differs in case. NFS datastores are browsed case-sensitively, but the export
+// may not be, in which case uploading replaces that file. Depending on
+// case_conflict, such a file is logged or returned as an error.
+func checkCaseConflict(ctx context.Context, ds *object.Datastore, f *file) error {
+	dsType, err := ds.Type(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading type of datastore %s: %w", ds.Name(), err)
+	}
+	if !isCaseSensitiveDatastore(dsType) {
+		return nil
+	}
+
+	variant, err := datastoreCaseVariant(ctx, ds, f.browser, f.destinationFile)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			// The directory doesn't exist yet
+			return nil
+		}
+		return fmt.Errorf("error listing directory of destination_file %s: %w", ds.Path(f.destinationFile), err)
+	}
+	if variant == "" {
+		return nil
+	}
+
+	if f.caseConflict == "error" {
+		return fmt.Errorf("destination_file %s only differs in case from existing file %s, which it may overwrite",
+			ds.Path(f.destinationFile), ds.Path(variant))
+	}
+	log.Printf("[WARN] destination_file %s only differs in case from existing file %s, which it may overwrite",
+		ds.Path(f.destinationFile), ds.Path(variant))
+	return nil
+}
+
+// contentTypes maps file extensions commonly found on datastores to the
+// content type they are uploaded with. Extensions not listed here fall back
+// to the mime package, and then to application/octet-stream.
+var contentTypes = map[string]string{
+	".cfg":  "text/plain",
+	".conf": "text/plain",
+	".ini":  "text/plain",
+	".iso":  "application/octet-stream",
+	".json": "application/json",
+	".log":  "text/plain",
+	".ovf":  "text/xml",
+	".txt":  "text/plain",
+	".vmdk": "application/octet-stream",
+	".vmx":  "text/plain",
+	".xml":  "text/xml",
+}
+
+// detectContentType returns the content type to upload a file with, based on
+// the extension of its destination path.
+func detectContentType(destinationFile string) string {
+	ext := strings.ToLower(path.Ext(destinationFile))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); ext != "" && t != "" {
+		return t
+	}
+	return soap.DefaultUpload.Type
+}
+
+// checkFreeSpace returns an error if the source file of the given size, plus the margin
+// that should be kept free, doesn't fit on the datastore. Failing before the
+// upload starts avoids leaving a partial file behind.
+func checkFreeSpace(ctx context.Context, client *govmomi.Client, ds *object.Datastore, f *file, size int64) error {
+	summary, err := datastoreSummary(ctx, client, ds)
+	if err != nil {
+		return fmt.Errorf("error reading free space of datastore %s: %w", ds.Name(), err)
+	}
+
+	if size+f.freeSpaceMargin > summary.FreeSpace {
+		return fmt.Errorf("source_file %s (%d bytes) does not fit on datastore %s: %d bytes free, free_space_margin is %d bytes",
+			f.sourceFile, size, summary.Name, summary.FreeSpace, f.freeSpaceMargin)
+	}
+	return nil
+}
+
+// prepareSourceFile checks the source of a file before it is uploaded. When
+// content is set, it is written to a temporary file that becomes the source
+// file; its path is returned so the caller can remove it when done.
+func prepareSourceFile(ctx context.Context, d *schema.ResourceData, f *file) (string, error) {
+	var contentFile string
+	if v, ok := d.GetOk("content"); ok {
+		if f.copyFile || f.download {
+			return "", fmt.Errorf("content cannot be used together with source_datastore or download")
+		}
+		var err error
+		contentFile, err = writeContentFile(v.(string))
+		if err != nil {
+			return "", err
+		}
+		f.sourceFile = contentFile
+	} else if v, ok := d.GetOk("source_files"); ok {
+		if f.copyFile || f.download {
+			return "", fmt.Errorf("source_files cannot be used together with source_datastore or download")
+		}
+		sourceFile, err := selectSourceFile(ctx, interfacesToStrings(v.([]interface{})))
+		if err != nil {
+			return "", err
+		}
+		f.sourceFile = sourceFile
+		f.sourceUsed = sourceFile
+	} else if f.sourceFile == "" {
+		return "", fmt.Errorf("one of source_file, source_files or content must be set")
+	}
+
+	if strings.HasPrefix(f.sourceFile, "s3://") {
+		return "", fmt.Errorf("s3:// URLs are not supported in source_file, use a pre-signed https:// URL of the object instead")
+	}
+	if isURLSource(f.sourceFile) {
+		if f.copyFile || f.download {
+			return "", fmt.Errorf("source_file cannot be a URL together with source_datastore or download")
+		}
+		return "", nil
+	}
+
+	if !f.copyFile && !f.download {
+		sourceFile, err := validateSourceFile(f.sourceFile)
+		if err != nil {
+			if contentFile != "" {
+				os.Remove(contentFile)
+			}
+			return "", err
+		}
+		f.sourceFile = sourceFile
+	}
+	return contentFile, nil
+}
+
+// checkSourceExtension applies enforce_matching_extension to the upload of f
+// from a local source file. Downloads, copies and URL sources are skipped, as
+// one side may be a directory or have no meaningful name, and so is content,
+// whose source is a temporary file.
+func checkSourceExtension(d *schema.ResourceData, f *file, contentFile string) error {
+	mode := d.Get("enforce_matching_extension").(string)
+	if mode == "" || contentFile != "" || f.download || f.copyFile || isURLSource(f.sourceFile) {
+		return nil
+	}
+
+	err := matchingExtension(trimCompressionExtension(f.sourceFile, f.decompress), f.destinationFile)
+	if err == nil {
+		return nil
+	}
+	if mode == "error" {
+		return err
+	}
+	log.Printf("[WARN] %s", err)
+	return nil
+}
+
+// matchingExtension returns an error if the extensions of a source and a
+// destination path differ, ignoring case.
+func matchingExtension(source, destination string) error {
+	sourceExt := filepath.Ext(source)
+	destinationExt := path.Ext(destination)
+	if strings.EqualFold(sourceExt, destinationExt) {
+		return nil
+	}
+	return fmt.Errorf("extension %q of destination_file %s doesn't match extension %q of source_file %s",
+		destinationExt, destination, sourceExt, source)
+}
+
+// selectSourceFile returns the first of the source_files candidates that can
+// be read: a local file that exists, or a URL that answers with the file.
+// Candidates are only skipped for that; a failing upload from the selected
+// one isn't retried with the next. The error lists why each one was skipped.
+func selectSourceFile(ctx context.Context, candidates []string) (string, error) {
+	var errs []string
+	for _, c := range candidates {
+		var err error
+		if isURLSource(c) {
+			var res *http.Response
+			res, err = openSourceURL(ctx, c)
+			if err == nil {
+				res.Body.Close()
+			}
+		} else {
+			_, err = validateSourceFile(c)
+		}
+		if err == nil {
+			log.Printf("[DEBUG] using %s of source_files", redactSourceURL(c))
+			return c, nil
+		}
+
+		log.Printf("[WARN] skipping %s of source_files: %s", redactSourceURL(c), err)
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("none of source_files can be read:\n%s", strings.Join(errs, "\n"))
+}
+
+// setSourceFileHash records the sha256 hash of an uploaded local file, which
+// track_source_changes compares against. The hash is empty for copies and
+// downloads.
+func setSourceFileHash(d *schema.ResourceData, f *file) {
+	if f.download || f.copyFile || isURLSource(f.sourceFile) {
+		d.Set("source_file_hash", "")
+		return
+	}
+
+	hash, err := fileChecksum(f.sourceFile, "sha256")
+	if err != nil {
+		log.Printf("[WARN] unable to compute hash of %s: %s", f.sourceFile, err)
+		return
+	}
+	d.Set("source_file_hash", hash)
+}
+
+// setSourceFileMtime records the modification time of an uploaded local
+// file at the time of the upload. Like the hash, it is empty for copies and
+// downloads.
+func setSourceFileMtime(d *schema.ResourceData, f *file) {
+	if f.download || f.copyFile || isURLSource(f.sourceFile) {
+		d.Set("source_file_mtime", "")
+		return
+	}
+
+	st, err := os.Stat(f.sourceFile)
+	if err != nil {
+		log.Printf("[WARN] unable to read modification time of %s: %s", f.sourceFile, err)
+		return
+	}
+	d.Set("source_file_mtime", st.ModTime().UTC().Format(time.RFC3339))
+}
+
+// sourceFileNewer reports whether the local file path was modified after the
+// datastore file described by fi. If the datastore reports no modification
+// time, the modification time of path at the last upload, uploaded, is
+// compared against instead.
+func sourceFileNewer(path string, fi *types.FileInfo, uploaded string) (bool, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if fi.Modification != nil {
+		return st.ModTime().After(*fi.Modification), nil
+	}
+	if uploaded == "" {
+		return false, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, uploaded)
+	if err != nil {
+		return false, err
+	}
+	// The recorded time has no fractional seconds
+	return st.ModTime().Truncate(time.Second).After(t), nil
+}
+
+// newChecksumHash returns the hash for a checksum_type.
+func newChecksumHash(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "sha256":
+		return sha256.New(), nil
+	case "md5", "":
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum type %s", checksumType)
+	}
+}
+
+// writeContentFile writes content to a temporary file, so it can be uploaded
+// like a source_file. The caller removes the file when done.
+func writeContentFile(content string) (string, error) {
+	fh, err := ioutil.TempFile("", "terraform-vsphere-file")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary file for content: %w", err)
+	}
+	defer fh.Close()
+
+	_, err = fh.WriteString(content)
+	if err != nil {
+		os.Remove(fh.Name())
+		return "", fmt.Errorf("error writing content: %w", err)
+	}
+	return fh.Name(), nil
+}
+
+// validateSourceFile checks that a local source file exists, is a regular file
+// and can be read, so a bad path is reported before contacting vSphere. It
+// returns the path with symlinks resolved, which is the file that is uploaded.
+func validateSourceFile(sourceFile string) (string, error) {
+	lfi, err := os.Lstat(sourceFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("source_file %q does not exist", sourceFile)
+		}
+		return "", fmt.Errorf("source_file %q is not accessible: %w", sourceFile, err)
+	}
+
+	resolved := sourceFile
+	if lfi.Mode()&os.ModeSymlink != 0 {
+		resolved, err = filepath.EvalSymlinks(sourceFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				target, _ := os.Readlink(sourceFile)
+				return "", fmt.Errorf("source_file symlink target missing: %q points to %q, which does not exist", sourceFile, target)
+			}
+			return "", fmt.Errorf("source_file symlink %q cannot be resolved: %w", sourceFile, err)
+		}
+		log.Printf("[DEBUG] source_file %s resolves to %s", sourceFile, resolved)
+	}
+
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("source_file %q is not accessible: %w", sourceFile, err)
+	}
+	if fi.IsDir() {
+		return "", fmt.Errorf("source_file %q is a directory", sourceFile)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("source_file %q is not a regular file", sourceFile)
+	}
+
+	fh, err := os.Open(resolved)
+	if err != nil {
+		return "", fmt.Errorf("source_file %q is not readable: %w", sourceFile, err)
+	}
+	fh.Close()
+	return resolved, nil
+}
+
+// verifyChecksum computes the digest of the local copy of the file and
+// compares it against the expected checksum, if one was given. The computed
+// digest is recorded on the file so it can be persisted in state.
+func verifyChecksum(f *file, localPath string) error {
+	actual, err := fileChecksum(localPath, f.checksumType)
+	if err != nil {
+		return err
+	}
+
+	if f.sourceChecksum != "" && !strings.EqualFold(f.sourceChecksum, actual) {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s",
+			f.checksumType, localPath, f.sourceChecksum, actual)
+	}
+
+	f.sourceChecksum = actual
+	return nil
+}
+
+// verifyUpload checks that the size of the uploaded datastore file matches
+// the size of the local source file, and records the size and modification
+// time reported by the datastore browser.
+func verifyUpload(ctx context.Context, ds *object.Datastore, f *file, size int64) error {
+	remote, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err != nil {
+		return fmt.Errorf("error reading uploaded file %s: %w", ds.Path(f.destinationFile), err)
+	}
+
+	fi := remote.GetFileInfo()
+	if fi.FileSize != size {
+		return fmt.Errorf("uploaded file %s is incomplete: expected %d bytes, got %d bytes",
+			ds.Path(f.destinationFile), size, fi.FileSize)
+	}
+
+	f.size = fi.FileSize
+	f.lastModified = fileModification(fi)
+	return nil
+}
+
+// fileModification returns the modification time of a datastore file, or an
+// empty string for datastore types that don't report one.
+func fileModification(fi *types.FileInfo) string {
+	if fi.Modification == nil {
+		return ""
+	}
+	return fi.Modification.UTC().Format(time.RFC3339)
+}
+
+// fileChecksum returns the hex encoded digest of a local file
+func fileChecksum(path string, checksumType string) (string, error) {
+	h, err := newChecksumHash(checksumType)
+	if err != nil {
+		return "", err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error computing checksum: %w", err)
+	}
+	defer fh.Close()
+
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", fmt.Errorf("error computing checksum: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func resourceVSphereFileRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading file %s", d.Id())
+	f, err := expandFile(d)
+	if err != nil {
+		return err
+	}
+
+	// State written before source_status existed
+	if d.Get("source_status").(string) == "" {
+		d.Set("source_status", fileUploaded)
+	}
+
+	// If datastore names a datastore cluster, the file is on the member
+	// chosen at creation.
+	if v, ok := d.GetOk("datastore_member"); ok {
+		f.datastore = v.(string)
+	}
+
+	if f.sourceFile == "" {
+		// The candidate of source_files that was uploaded
+		f.sourceFile = d.Get("source_used").(string)
+	}
+
+	localPath := f.sourceFile
+	if f.download {
+		localPath = f.destinationFile
+	}
+
+	// URL sources aren't downloaded again to detect changes
+	if isURLSource(localPath) {
+		localPath = ""
+	}
+
+	trackSource := d.Get("track_source_changes").(bool) && localPath != "" && !f.download && !f.copyFile
+	ifNewer := d.Get("upload_if_newer").(bool) && localPath != "" && !f.download && !f.copyFile
+	if ifNewer {
+		// Compared against the datastore file below, rather than by content
+		localPath = ""
+	} else if v, ok := d.GetOk("source_file_hash"); ok && trackSource {
+		hash, err := fileChecksum(f.sourceFile, "sha256")
+		if err != nil {
+			log.Printf("[WARN] unable to compute hash of %s: %s", f.sourceFile, err)
+		} else if hash != v.(string) {
+			log.Printf("[INFO] local file %s has changed (sha256 %s, was %s)", f.sourceFile, hash, v.(string))
+			d.Set("source_status", fileChanged)
+		}
+		localPath = ""
+	} else if trackSource {
+		// Files uploaded before track_source_changes was set have no hash
+		// yet, so the current local file becomes the baseline.
+		setSourceFileHash(d, f)
+		localPath = ""
+	}
+
+	// Files uploaded from content or imported have no local file to compare
+	if v, ok := d.GetOk("source_checksum"); ok && localPath != "" {
+		var checksum string
+		var err error
+		if f.decompressing() && !f.download && !f.copyFile {
+			_, checksum, err = scanDecompressed(localPath, f.decompress, f.checksumType)
+		} else {
+			checksum, err = fileChecksum(localPath, f.checksumType)
+		}
+		if err != nil {
+			log.Printf("[WARN] unable to compute checksum of %s: %s", localPath, err)
+		} else if !strings.EqualFold(checksum, v.(string)) {
+			log.Printf("[INFO] local file %s has changed (checksum %s, was %s)", localPath, checksum, v.(string))
+			d.Set("source_status", fileChanged)
+			return nil
+		}
+	}
+
+	if f.download {
+		_, err := os.Stat(f.destinationFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Printf("[DEBUG] local file %s is gone", f.destinationFile)
+				d.SetId("")
+				return nil
+			}
+			return err
+		}
+		return nil
+	}
+
+	ctx, client, done, err := fileSession(d, meta)
+	if err != nil {
+		return err
+	}
+	defer done()
+	timeout := fileTimeout(d)
+
+	dc, err := getDatacenterContext(ctx, client, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %w", f.datacenter, err)
+	}
+
+	ds, err := lookupDatastoreContext(ctx, client, dc, f.datastore)
+	if v, ok := d.GetOk("datastore_id"); ok && isDatastoreNotFoundError(err) {
+		// The datastore was renamed, its managed object ID stays the same
+		log.Printf("[DEBUG] datastore %s not found, looking up %s", f.datastore, v.(string))
+		ds, err = lookupDatastoreContext(ctx, client, dc, v.(string))
+	}
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %w", f.datastore, err)
+	}
+
+	d.Set("datastore_member", ds.Name())
+	d.Set("datastore_id", ds.Reference().Value)
+
+	// Only the configured attributes are managed, others may be set on
+	// the datastore for other files or by other tools.
+	if configured := d.Get("custom_attributes").(map[string]interface{}); len(configured) > 0 {
+		values, err := readCustomAttributes(ctx, client, ds, configured)
+		if err != nil {
+			return err
+		}
+		d.Set("custom_attributes", values)
+	}
+
+	b, err := datastoreBrowser(ctx, client, dc, ds, f.host)
+	if err != nil {
+		return err
+	}
+
+	dsType, err := ds.Type(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading type of datastore %s: %w", ds.Name(), err)
+	}
+	d.Set("datastore_type", string(dsType))
+
+	info, err := statDatastoreFile(ctx, ds, b, f.destinationFile)
+	if isFileNotFoundError(err) && isCaseSensitiveDatastore(dsType) {
+		info, err = statDatastoreFileFold(ctx, ds, b, f.destinationFile)
+	}
+	if err != nil {
+		if isFileNotFoundError(err) {
+			log.Printf("[INFO] file %s is gone", ds.Path(f.destinationFile))
+			d.SetId("")
+			return nil
+		}
+		return timeoutError(ctx, "read", timeout, err)
+	}
+
+	fi := info.GetFileInfo()
+	if drift := datastoreFileDrift(d, fi); drift != "" {
+		log.Printf("[INFO] file %s %s", ds.Path(f.destinationFile), drift)
+		d.Set("source_status", fileChanged)
+		return nil
+	}
+
+	// A missing copy is uploaded again with all others
+	missing, copiesModified, err := missingCopy(ctx, client, dc, f, fi.FileSize)
+	if err != nil {
+		return timeoutError(ctx, "read", timeout, err)
+	}
+	if missing != "" {
+		d.Set("source_status", fileChanged)
+		return nil
+	}
+	d.Set("datastore_copies_modified", copiesModified)
+
+	if ifNewer {
+		newer, err := sourceFileNewer(f.sourceFile, fi, d.Get("source_file_mtime").(string))
+		if err != nil {
+			log.Printf("[WARN] unable to read modification time of %s: %s", f.sourceFile, err)
+		} else if newer {
+			log.Printf("[INFO] local file %s is newer than %s", f.sourceFile, ds.Path(f.destinationFile))
+			d.Set("source_status", fileChanged)
+		}
+	}
+
+	d.Set("size", int(fi.FileSize))
+	d.Set("last_modified", fileModification(fi))
+
+	dcPath, err := datacenterPath(ctx, dc, f.datacenter)
+	if err != nil {
+		return fmt.Errorf("error reading name of datacenter: %w", err)
+	}
+	d.Set("download_url", datastoreDownloadURL(client.URL(), dcPath, ds.Name(), f.destinationFile))
+	d.Set("datastore_path", ds.Path(f.destinationFile))
+
+	return nil
+}
+
+// datacenterPath returns the inventory path of dc relative to the root
+// folder, the form the datastore HTTP service expects. configured is the
+// datacenter as set on the resource, which is found by that path; only the
+// default datacenter, always at the root, needs its name read.
+func datacenterPath(ctx context.Context, dc *object.Datacenter, configured string) (string, error) {
+	if p := strings.Trim(configured, "/"); p != "" {
+		return p, nil
+	}
+
+	var mdc mo.Datacenter
+	err := dc.Properties(ctx, dc.Reference(), []string{"name"}, &mdc)
+	if err != nil {
+		return "", err
+	}
+	return mdc.Name, nil
+}
+
+// datastoreDownloadURL returns the URL of the datastore HTTP service for the
+// file p, which tools that don't speak the vSphere API can fetch it from with
+// the credentials of the vSphere server u.
+func datastoreDownloadURL(u *url.URL, dcPath, dsName, p string) string {
+	du := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "/folder/" + strings.TrimLeft(p, "/"),
+		RawQuery: url.Values{
+			"dcPath": []string{dcPath},
+			"dsName": []string{dsName},
+		}.Encode(),
+	}
+	return du.String()
+}
+
+// datastoreFileDrift compares the size and modification time the datastore
+// browser reports for a file with those in state, and describes the change
+// if they differ. Only this metadata is compared, so drift is detected
+// without downloading the file, however large it is.
+func datastoreFileDrift(d *schema.ResourceData, fi *types.FileInfo) string {
+	if v, ok := d.GetOk("size"); ok && int64(v.(int)) != fi.FileSize {
+		return fmt.Sprintf("changed size on the datastore (%d bytes, was %d)", fi.FileSize, v.(int))
+	}
+
+	modified := fileModification(fi)
+	if v, ok := d.GetOk("last_modified"); ok && modified != "" && modified != v.(string) {
+		return fmt.Sprintf("was modified on the datastore at %s (was %s)", modified, v.(string))
+	}
+
+	return ""
+}
+
+func resourceVSphereFileUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] updating file %s", d.Id())
+	// A file placed relative to a vm follows it to its new home directory.
+	vmHomeChanged := d.Get("vm_relative_path").(string) != "" && (d.HasChange("vm") || d.HasChange("datacenter"))
+	moved := d.HasChange("datacenter") || d.HasChange("datastore") || d.HasChange("destination_file") || vmHomeChanged
+	sourceChanged := fileSourceChanged(d)
+	attachChanged := d.HasChange("attach_to_vm")
+	customChanged := d.HasChange("custom_attributes")
+	if !moved && !sourceChanged && !attachChanged && !customChanged {
+		// Only fields kept in state, such as description, changed.
+		return nil
+	}
+
+	if err := checkWritable(meta, "update vsphere_file"); err != nil {
+		return err
+	}
+
+	oldDatacenter, newDatacenter := d.GetChange("datacenter")
+	oldDatastore, newDatastore := d.GetChange("datastore")
+	oldDestinationFile, newDestinationFile := d.GetChange("destination_file")
+
+	// Until the file has been moved, a failed update must leave state with
+	// its old location, so that the next apply tries the move again.
+	locationUpdated := !moved
+	defer restoreFileLocation(d, &locationUpdated)
+
+	f, err := expandFile(d)
+	if err != nil {
+		return err
+	}
+
+	// A file that on_conflict uploaded to another name moves from there to
+	// destination_file.
+	if v := d.Get("destination_used").(string); v != "" {
+		oldDestinationFile = v
+		if moved {
+			f.destinationFile = normalizeDatastorePath(newDestinationFile.(string))
+		}
+	}
+
+	ctx, client, done, err := fileSession(d, meta)
+	if err != nil {
+		return err
+	}
+	defer done()
+	timeout := fileTimeout(d)
+
+	if vmHomeChanged {
+		datastore, destinationFile, err := vmHomePath(ctx, client, f.datacenter, f.vm, d.Get("vm_relative_path").(string))
+		if err != nil {
+			return err
+		}
+		newDatastore, newDestinationFile = datastore, destinationFile
+		f.datastore, f.destinationFile = datastore, destinationFile
+		d.Set("datastore", datastore)
+		d.Set("destination_file", destinationFile)
+	}
+
+	if f.download {
+		if sourceChanged || d.HasChange("datacenter") || d.HasChange("datastore") {
+			// The source of the download changed, so fetch it again.
+			f.force = true
+			f.replacing = true
+			err := createFile(ctx, client, f)
+			if err != nil {
+				return timeoutError(ctx, "update", timeout, err)
+			}
+			locationUpdated = true
+			if oldDestinationFile.(string) != f.destinationFile {
+				os.Remove(oldDestinationFile.(string))
+			}
+			d.Set("source_checksum", f.sourceChecksum)
+			d.Set("datastore_member", f.datastoreMember)
+		} else {
+			err := os.Rename(oldDestinationFile.(string), newDestinationFile.(string))
+			if err != nil {
+				return fmt.Errorf("error moving local file: %w", err)
+			}
+			locationUpdated = true
+		}
+		d.SetId(datastoreObjectID(f.datastore, f.datacenter, f.destinationFile))
+		return nil
+	}
+
+	// If datastore names a datastore cluster, the file is on the member
+	// chosen at creation, or at the last move.
+	oldMember := oldDatastore.(string)
+	if v, ok := d.GetOk("datastore_member"); ok {
+		oldMember = v.(string)
+	}
+	f.datastore = oldMember
+
+	// Eject the file from the CD-ROM it is attached to before it moves, and
+	// attach it again at the end.
+	oldAttach, _ := d.GetChange("attach_to_vm")
+	if (moved || attachChanged) && expandFileAttachment(oldAttach) != nil {
+		old := *f
+		old.datacenter = oldDatacenter.(string)
+		old.destinationFile = normalizeDatastorePath(oldDestinationFile.(string))
+		old.attachToVM = expandFileAttachment(oldAttach)
+		err := detachFile(ctx, client, &old)
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+	}
+
+	// Custom attributes follow the file to its new datastore
+	datastoreID := d.Get("datastore_id").(string)
+
+	// Move the file first, so a changed source is uploaded to its new location.
+	if moved {
+		oldDc, err := getDatacenterContext(ctx, client, oldDatacenter.(string))
+		if err != nil {
+			return err
+		}
+
+		newDc, err := getDatacenterContext(ctx, client, newDatacenter.(string))
+		if err != nil {
+			return err
+		}
+
+		oldPath := normalizeDatastorePath(oldDestinationFile.(string))
+		newPath := normalizeDatastorePath(newDestinationFile.(string))
+
+		oldDs, err := lookupDatastoreContext(ctx, client, oldDc, oldMember)
+		if err != nil {
+			return fmt.Errorf("error finding datastore %q: %w", oldMember, err)
+		}
+
+		// Copies move first, so that a failure leaves the file at its old
+		// location and the next apply tries the move again.
+		err = moveCopies(ctx, client, oldDc, newDc, f, oldPath, newPath)
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+
+		// A rename within the same directory stays on the same datastore
+		// member, and its directory exists already.
+		rename := isRename(oldDatacenter.(string), newDatacenter.(string), oldDatastore.(string), newDatastore.(string), oldPath, newPath)
+
+		newDs := oldDs
+		if !rename {
+			newMember := oldMember
+			if newDatastore.(string) != oldDatastore.(string) {
+				newMember = newDatastore.(string)
+			}
+			newDs, err = resolveDatastore(ctx, client, newDc, newMember)
+			if err != nil {
+				return fmt.Errorf("error finding datastore %q: %w", newMember, err)
+			}
+		}
+
+		fm := object.NewFileManager(client.Client)
+		if d.Get("create_directories").(bool) && !rename {
+			created, err := createDirectory(ctx, fm, newDs, newDc, newPath)
+			if err != nil {
+				return err
+			}
+			if len(created) > 0 {
+				d.Set("created_directories", append(d.Get("created_directories").([]interface{}), stringsToInterfaces(created)...))
+			}
+		}
+
+		if rename && renamedAlready(ctx, oldDs, oldPath, newPath) {
+			// An earlier apply renamed the file, but failed before
+			// recording it.
+			log.Printf("[INFO] %s was already renamed to %s", oldDs.Path(oldPath), path.Base(newPath))
+		} else {
+			moveTask, err := fm.MoveDatastoreFile(ctx, oldDs.Path(oldPath), oldDc, newDs.Path(newPath), newDc, d.Get("force").(bool))
+			if err != nil {
+				return timeoutError(ctx, "update", timeout, err)
+			}
+
+			taskID := moveTask.Reference().Value
+			d.Set("last_task_id", taskID)
+			if rename {
+				log.Printf("[INFO] renaming %s to %s in task %s", oldDs.Path(oldPath), path.Base(newPath), taskID)
+			} else {
+				log.Printf("[INFO] moving %s to %s in task %s", oldDs.Path(oldPath), newDs.Path(newPath), taskID)
+			}
+
+			_, err = waitForTask(ctx, moveTask, newProgressLogger(ctx, "task "+taskID))
+			if err != nil {
+				if terr, ok := err.(task.Error); ok {
+					err = fmt.Errorf("moving %s failed in task %s: %s", oldDs.Path(oldPath), taskID, terr.LocalizedMessage)
+				}
+				return timeoutError(ctx, "update", timeout, err)
+			}
+		}
+
+		f.datastore = newDs.Name()
+		datastoreID = newDs.Reference().Value
+		d.Set("datastore_member", f.datastore)
+		d.Set("destination_used", "")
+		d.SetId(datastoreObjectID(f.datastore, f.datacenter, f.destinationFile))
+		locationUpdated = true
+	}
+
+	if sourceChanged {
+		// The destination is this resource's own file, so it is always
+		// replaced. The stored checksum belongs to the old source.
+		f.force = true
+		f.replacing = true
+
+		contentFile, err := prepareSourceFile(ctx, d, f)
+		if err != nil {
+			return err
+		}
+		if contentFile != "" {
+			defer os.Remove(contentFile)
+		}
+
+		err = checkSourceExtension(d, f, contentFile)
+		if err != nil {
+			return err
+		}
+
+		err = createFile(ctx, client, f)
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+
+		_, err = uploadCopies(ctx, client, f)
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+		d.Set("datastore_copies_modified", f.copiesModified)
+
+		d.Set("source_checksum", f.sourceChecksum)
+		d.Set("source_used", f.sourceUsed)
+		d.Set("size", int(f.size))
+		d.Set("last_modified", f.lastModified)
+		setSourceFileHash(d, f)
+		setSourceFileMtime(d, f)
+		setUploadStats(d, f)
+
+		if d.Get("wait_for_host_visibility").(bool) {
+			err = waitForHostVisibility(ctx, client, f, hostVisibilityTimeout(d))
+			if err != nil {
+				return timeoutError(ctx, "update", timeout, err)
+			}
+		}
+
+		if g := expandGuestVerify(d.Get("guest_verify")); g != nil {
+			err = verifyGuestFile(ctx, client, f.datacenter, g)
+			if err != nil {
+				return timeoutError(ctx, "update", timeout, err)
+			}
+		}
+	}
+
+	if (moved || attachChanged) && f.attachToVM != nil {
+		err := attachFile(ctx, client, f)
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+	}
+
+	if moved || customChanged {
+		oldAttributes, newAttributes := d.GetChange("custom_attributes")
+		old := oldAttributes.(map[string]interface{})
+		if oldID := d.Get("datastore_id").(string); oldID != datastoreID {
+			err := setCustomAttributes(ctx, client, oldID, old, nil, false)
+			if err != nil {
+				return timeoutError(ctx, "update", timeout, err)
+			}
+			old = nil
+		}
+		err := setCustomAttributes(ctx, client, datastoreID, old, newAttributes.(map[string]interface{}), d.Get("create_custom_attributes").(bool))
+		if err != nil {
+			return timeoutError(ctx, "update", timeout, err)
+		}
+		d.Set("datastore_id", datastoreID)
+	}
+
+	return nil
+}
+
+// fileSourceChanged returns whether the file has to be uploaded again in
+// Update: its source changed, or Read found that the file no longer matches
+// what was uploaded and set source_status.
+func fileSourceChanged(d *schema.ResourceData) bool {
+	if d.HasChange("source_file") || d.HasChange("source_files") || d.HasChange("content") {
+		return true
+	}
+	status, _ := d.GetChange("source_status")
+	return status.(string) == fileChanged
+}
+
+// isRename returns whether a move of a file only changes its base name,
+// keeping its datacenter, datastore and directory.
+func isRename(oldDatacenter, newDatacenter, oldDatastore, newDatastore, oldPath, newPath string) bool {
+	return oldDatacenter == newDatacenter &&
+		oldDatastore == newDatastore &&
+		path.Dir(oldPath) == path.Dir(newPath) &&
+		path.Base(oldPath) != path.Base(newPath)
+}
+
+// renamedAlready returns whether the file at oldPath in ds has been renamed
+// to newPath: only newPath exists. Failing to tell is left to the rename.
+func renamedAlready(ctx context.Context, ds *object.Datastore, oldPath, newPath string) bool {
+	_, err := ds.Stat(ctx, oldPath)
+	if !isFileNotFoundError(err) {
+		return false
+	}
+	_, err = ds.Stat(ctx, newPath)
+	return err == nil
+}
+
+// restoreFileLocation sets the location of a file in d back to the one in
+// state, unless updated is set. Otherwise state would record the new location
+// of a file that failed to move there.
+func restoreFileLocation(d *schema.ResourceData, updated *bool) {
+	if *updated {
+		return
+	}
+	for _, k := range []string{"datacenter", "datastore", "destination_file"} {
+		old, _ := d.GetChange(k)
+		d.Set(k, old)
+	}
+}
+
+func resourceVSphereFileDelete(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "delete vsphere_file"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] deleting file %s", d.Id())
+	f, err := expandFile(d)
+	if err != nil {
+		return err
+	}
+
+	// If datastore names a datastore cluster, the file is on the member
+	// chosen at creation.
+	if v, ok := d.GetOk("datastore_member"); ok {
+		f.datastore = v.(string)
+	}
+
+	f.lastModified = d.Get("last_modified").(string)
+	f.sourceChecksum = d.Get("source_checksum").(string)
+	f.size = int64(d.Get("size").(int))
+	for _, v := range d.Get("created_directories").([]interface{}) {
+		f.createdDirectories = append(f.createdDirectories, v.(string))
+	}
+
+	ctx, client, done, err := fileSession(d, meta)
+	if err != nil {
+		return err
+	}
+	defer done()
+	timeout := fileTimeout(d)
+
+	// The copies go first, so the resource stays in state until all of
+	// them are deleted.
+	err = deleteCopies(ctx, client, f)
+	if err != nil {
+		return timeoutError(ctx, "delete", timeout, err)
+	}
+
+	err = deleteFile(ctx, client, f)
+	if err != nil {
+		return timeoutError(ctx, "delete", timeout, err)
+	}
+
+	// A replacing file has set the attributes again already
+	if !f.replaced {
+		err = setCustomAttributes(ctx, client, d.Get("datastore_id").(string), d.Get("custom_attributes").(map[string]interface{}), nil, false)
+		if err != nil {
+			return timeoutError(ctx, "delete", timeout, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// fileAttachment is the CD-ROM of a virtual machine that an ISO file is
+// attached to.
+type fileAttachment struct {
+	vm string
+
+	// deviceKey is the key of the CD-ROM device, or 0 for the first one.
+	deviceKey int32
+}
+
+// expandFileAttachment reads an attach_to_vm block, returning nil if there
+// is none.
+func expandFileAttachment(v interface{}) *fileAttachment {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	return &fileAttachment{
+		vm:        m["vm"].(string),
+		deviceKey: int32(m["device_key"].(int)),
+	}
+}
+
+// findCdrom finds the virtual machine of a in dc and its CD-ROM device.
+func findCdrom(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, a *fileAttachment) (*object.VirtualMachine, object.VirtualDeviceList, *types.VirtualCdrom, error) {
+	finder := find.NewFinder(client.Client, true)
+	finder = finder.SetDatacenter(dc)
+
+	vm, err := finder.VirtualMachine(ctx, a.vm)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error finding vm %s: %w", a.vm, err)
+	}
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading devices of vm %s: %w", a.vm, err)
+	}
+
+	if a.deviceKey == 0 {
+		cdrom, err := devices.FindCdrom("")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("vm %s has no CD-ROM device", a.vm)
+		}
+		return vm, devices, cdrom, nil
+	}
+
+	device := devices.FindByKey(a.deviceKey)
+	if device == nil {
+		return nil, nil, nil, fmt.Errorf("vm %s has no device with key %d", a.vm, a.deviceKey)
+	}
+	cdrom, ok := device.(*types.VirtualCdrom)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("device %d of vm %s is not a CD-ROM device", a.deviceKey, a.vm)
+	}
+	return vm, devices, cdrom, nil
+}
+
+// findFileDatastore finds the datacenter and datastore of f. A datastore
+// cluster must have been resolved to its member already.
+func findFileDatastore(ctx context.Context, client *govmomi.Client, f *file) (*object.Datacenter, *object.Datastore, error) {
+	dc, err := getDatacenterContext(ctx, client, f.datacenter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding datacenter %q: %w", f.datacenter, err)
+	}
+	ds, err := lookupDatastoreContext(ctx, client, dc, f.datastore)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding datastore %q: %w", f.datastore, err)
+	}
+	return dc, ds, nil
+}
+
+// attachFile backs the CD-ROM of f.attachToVM with the uploaded file.
+func attachFile(ctx context.Context, client *govmomi.Client, f *file) error {
+	dc, ds, err := findFileDatastore(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	vm, devices, cdrom, err := findCdrom(ctx, client, dc, f.attachToVM)
+	if err != nil {
+		return err
+	}
+
+	iso := ds.Path(f.destinationFile)
+	log.Printf("[DEBUG] attaching %s to CD-ROM %d of vm %s", iso, cdrom.Key, f.attachToVM.vm)
+	err = vm.EditDevice(ctx, devices.InsertIso(cdrom, iso))
+	if err != nil {
+		return fmt.Errorf("error attaching %s to vm %s: %w", iso, f.attachToVM.vm, err)
+	}
+	return nil
+}
+
+// detachFile ejects the file from the CD-ROM of f.attachToVM.
+func detachFile(ctx context.Context, client *govmomi.Client, f *file) error {
+	dc, ds, err := findFileDatastore(ctx, client, f)
+	if err != nil {
+		return err
+	}
+	return detachISO(ctx, client, dc, ds.Path(f.destinationFile), f.attachToVM)
+}
+
+// detachISO ejects the ISO file iso from the CD-ROM of a. Nothing is changed
+// if the virtual machine is gone or the CD-ROM no longer holds the file.
+func detachISO(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, iso string, a *fileAttachment) error {
+	vm, devices, cdrom, err := findCdrom(ctx, client, dc, a)
+	if err != nil {
+		log.Printf("[WARN] not detaching %s: %s", iso, err)
+		return nil
+	}
 
-	log.Printf("[DEBUG] creating file: %#v", d)
-	client := meta.(*govmomi.Client)
+	backing, ok := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo)
+	if !ok || backing.FileName != iso {
+		log.Printf("[DEBUG] CD-ROM %d of vm %s no longer holds %s", cdrom.Key, a.vm, iso)
+		return nil
+	}
 
-	f := file{}
+	log.Printf("[DEBUG] detaching %s from CD-ROM %d of vm %s", iso, cdrom.Key, a.vm)
+	err = vm.EditDevice(ctx, devices.EjectIso(cdrom))
+	if err != nil {
+		return fmt.Errorf("error detaching %s from vm %s: %w", iso, a.vm, err)
+	}
+	return nil
+}
 
-	if v, ok := d.GetOk("datacenter"); ok {
-		f.datacenter = v.(string)
+// fileClient returns the client that a file is managed with. This is the
+// provider's client, unless the resource sets run_as, vsphere_server or
+// allow_unverified_ssl: then it is a session of its own, so that these only
+// apply to this resource. The returned function logs it out again. The
+// provider's credentials are only sent to the provider's server, with its TLS
+// settings, so another server or unverified TLS need run_as.
+func fileClient(d *schema.ResourceData, meta interface{}) (*govmomi.Client, func(), error) {
+	client := meta.(*govmomi.Client)
+	config := clientConfig(client)
+	dedicated := false
+
+	_, runAs := d.GetOk("run_as")
+	if runAs {
+		v := d.Get("run_as").([]interface{})[0].(map[string]interface{})
+		config.User = v["user"].(string)
+		config.Password = v["password"].(string)
+		config.Credentials = nil
+		dedicated = true
 	}
 
-	if v, ok := d.GetOk("datastore"); ok {
-		f.datastore = v.(string)
-	} else {
-		return fmt.Errorf("datastore argument is required")
+	if v, ok := d.GetOk("vsphere_server"); ok && v.(string) != config.VSphereServer {
+		if !runAs {
+			return nil, nil, fmt.Errorf("vsphere_server %q is not the provider's, set run_as to log in to it", v.(string))
+		}
+		config.VSphereServer = v.(string)
+		dedicated = true
 	}
 
-	if v, ok := d.GetOk("source_file"); ok {
-		f.sourceFile = v.(string)
-	} else {
-		return fmt.Errorf("source_file argument is required")
+	if d.Get("allow_unverified_ssl").(bool) && !config.InsecureFlag {
+		if !runAs {
+			return nil, nil, fmt.Errorf("allow_unverified_ssl needs run_as, the provider's credentials are only sent over verified TLS")
+		}
+		log.Printf("[WARN] not verifying the TLS certificate of %s for %s", config.VSphereServer, d.Id())
+		config.InsecureFlag = true
+		dedicated = true
 	}
 
-	if v, ok := d.GetOk("destination_file"); ok {
-		f.destinationFile = v.(string)
-	} else {
-		return fmt.Errorf("destination_file argument is required")
+	if !dedicated {
+		return client, func() {}, nil
 	}
+	return newSession(config)
+}
 
-	err := createFile(client, &f)
+// fileSession returns the client for d, see fileClient, and a context bounded
+// by the timeout of d. The returned function cancels the context and logs the
+// client out.
+func fileSession(d *schema.ResourceData, meta interface{}) (context.Context, *govmomi.Client, func(), error) {
+	client, logout, err := fileClient(d, meta)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
-	d.SetId(fmt.Sprintf("[%v] %v/%v", f.datastore, f.datacenter, f.destinationFile))
-	log.Printf("[INFO] Created file: %s", f.destinationFile)
-
-	return resourceVSphereFileRead(d, meta)
+	ctx, cancel := context.WithTimeout(context.Background(), fileTimeout(d))
+	return ctx, client, func() {
+		cancel()
+		logout()
+	}, nil
 }
 
-func createFile(client *govmomi.Client, f *file) error {
+// expandFile reads the configuration of a file resource. Fields that only
+// some operations need, such as the state kept for Delete, are left to them.
+func expandFile(d *schema.ResourceData) (*file, error) {
+	f := &file{
+		sourceDatacenter:   d.Get("source_datacenter").(string),
+		datacenter:         d.Get("datacenter").(string),
+		sourceDatastore:    d.Get("source_datastore").(string),
+		datastore:          d.Get("datastore").(string),
+		host:               d.Get("host").(string),
+		vm:                 d.Get("vm").(string),
+		attachToVM:         expandFileAttachment(d.Get("attach_to_vm")),
+		verifyBootable:     expandBootCheck(d.Get("verify_bootable")),
+		sourceFile:         d.Get("source_file").(string),
+		destinationFile:    d.Get("destination_file").(string),
+		createDirectories:  d.Get("create_directories").(bool),
+		uploadRetries:      d.Get("upload_retries").(int),
+		download:           d.Get("download").(bool),
+		force:              d.Get("force").(bool),
+		onConflict:         d.Get("on_conflict").(string),
+		skipIfMatching:     d.Get("skip_if_matching_checksum").(bool),
+		caseConflict:       d.Get("case_conflict").(string),
+		contentType:        d.Get("content_type").(string),
+		uploadMethod:       d.Get("upload_method").(string),
+		freeSpaceMargin:    int64(d.Get("free_space_margin").(int)),
+		maxUploadBandwidth: int64(d.Get("max_upload_bandwidth").(int)),
+		cleanupGlob:        d.Get("cleanup_glob").(string),
+		deleteGuard:        d.Get("delete_guard").(bool),
+		checksumType:       d.Get("checksum_type").(string),
+		expectedFormat:     d.Get("expected_format").(string),
+		decompress:         d.Get("decompress").(string),
+	}
+	f.copyFile = f.sourceDatastore != ""
+
+	// Without on_conflict, force decides whether to overwrite
+	switch f.onConflict {
+	case "overwrite":
+		f.force = true
+	case "":
+		f.onConflict = "fail"
+		if f.force {
+			f.onConflict = "overwrite"
+		}
+	}
 
-	finder := find.NewFinder(client.Client, true)
+	// on_conflict may have uploaded the file to another name
+	if v := d.Get("destination_used").(string); v != "" {
+		f.destinationFile = v
+	}
 
-	dc, err := finder.Datacenter(context.TODO(), f.datacenter)
-	if err != nil {
-		return fmt.Errorf("error %s", err)
+	// With datastores, the file is on the first, and copies are on the
+	// others. Once uploaded, the copies are on the members chosen then.
+	datastores := interfacesToStrings(d.Get("datastores").([]interface{}))
+	if f.datastore == "" && len(datastores) > 0 {
+		f.datastore = datastores[0]
+	}
+	f.copies = interfacesToStrings(d.Get("datastore_copies").([]interface{}))
+	if len(f.copies) == 0 && len(datastores) > 1 {
+		f.copies = datastores[1:]
+	}
+	f.copiesModified = interfacesToStrings(d.Get("datastore_copies_modified").([]interface{}))
+
+	// vm_relative_path sets both from the home directory of vm
+	if d.Get("vm_relative_path").(string) == "" {
+		if f.datastore == "" {
+			return nil, fmt.Errorf("datastore argument is required")
+		}
+		if f.destinationFile == "" {
+			return nil, fmt.Errorf("destination_file argument is required")
+		}
+	}
+
+	f.normalizePaths()
+	return f, nil
+}
+
+func deleteFile(ctx context.Context, client *govmomi.Client, f *file) error {
+
+	if f.download {
+		err := os.Remove(f.destinationFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error deleting local file: %w", err)
+		}
+		return nil
 	}
-	finder = finder.SetDatacenter(dc)
 
-	ds, err := getDatastore(finder, f.datastore)
+	dc, ds, err := findFileDatastore(ctx, client, f)
 	if err != nil {
-		return fmt.Errorf("error %s", err)
+		return err
 	}
 
-	dsurl, err := ds.URL(context.TODO(), dc, f.destinationFile)
+	f.browser, err = datastoreBrowser(ctx, client, dc, ds, f.host)
 	if err != nil {
 		return err
 	}
 
-	p := soap.DefaultUpload
-	err = client.Client.UploadFile(f.sourceFile, dsurl, &p)
+	f.replaced, err = isReplacedFile(ctx, ds, f)
 	if err != nil {
-		return fmt.Errorf("error %s", err)
+		return err
+	}
+	if f.replaced {
+		return nil
 	}
-	return nil
-}
 
-func resourceVSphereFileRead(d *schema.ResourceData, meta interface{}) error {
+	// DeleteDatastoreFile removes directories with everything in them, so
+	// never hand it a directory where the file was.
+	info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err == nil && isDatastoreDirectory(info) {
+		return fmt.Errorf("destination_file %s is a directory, not deleting it as a file", ds.Path(f.destinationFile))
+	}
 
-	log.Printf("[DEBUG] reading file: %#v", d)
-	f := file{}
+	if f.deleteGuard && err == nil {
+		err = checkDeleteGuard(ctx, client, dc, ds, f, info.GetFileInfo())
+		if err != nil {
+			return err
+		}
+	}
 
-	if v, ok := d.GetOk("datacenter"); ok {
-		f.datacenter = v.(string)
+	if f.attachToVM != nil {
+		err = detachISO(ctx, client, dc, ds.Path(f.destinationFile), f.attachToVM)
+		if err != nil {
+			return err
+		}
 	}
 
-	if v, ok := d.GetOk("datastore"); ok {
-		f.datastore = v.(string)
-	} else {
-		return fmt.Errorf("datastore argument is required")
+	fm := object.NewFileManager(client.Client)
+	task, err := fm.DeleteDatastoreFile(ctx, ds.Path(f.destinationFile), dc)
+	if err != nil {
+		return err
 	}
 
-	if v, ok := d.GetOk("source_file"); ok {
-		f.sourceFile = v.(string)
-	} else {
-		return fmt.Errorf("source_file argument is required")
+	_, err = waitForTask(ctx, task, nil)
+	if err != nil {
+		return err
 	}
 
-	if v, ok := d.GetOk("destination_file"); ok {
-		f.destinationFile = v.(string)
-	} else {
-		return fmt.Errorf("destination_file argument is required")
+	if f.cleanupGlob != "" {
+		err = cleanupFiles(ctx, fm, ds, f.browser, dc, f.destinationFile, f.cleanupGlob)
+		if err != nil {
+			return err
+		}
 	}
 
-	client := meta.(*govmomi.Client)
-	finder := find.NewFinder(client.Client, true)
+	removeDirectories(ctx, fm, ds, dc, f.createdDirectories)
+	return nil
+}
 
-	dc, err := finder.Datacenter(context.TODO(), f.datacenter)
-	if err != nil {
-		return fmt.Errorf("error %s", err)
+// checkDeleteGuard returns an error if the datastore file fi of f no longer
+// matches the file that was uploaded: its size differs, or the datastore
+// reports a digest of the same type as the stored checksum that differs.
+// Without a digest, only the size is compared.
+func checkDeleteGuard(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, ds *object.Datastore, f *file, fi *types.FileInfo) error {
+	if fi.FileSize != f.size {
+		return fmt.Errorf("%s was changed outside of Terraform (size %d, uploaded %d), not deleting it because delete_guard is set",
+			ds.Path(f.destinationFile), fi.FileSize, f.size)
 	}
-	finder = finder.SetDatacenter(dc)
 
-	ds, err := getDatastore(finder, f.datastore)
-	if err != nil {
-		return fmt.Errorf("error %s", err)
+	if f.sourceChecksum == "" {
+		return nil
 	}
 
-	_, err = ds.Stat(context.TODO(), f.destinationFile)
+	checksumType, remote, err := datastoreFileDigest(ctx, client, dc, ds, f.destinationFile)
 	if err != nil {
-		d.SetId("")
-		return err
+		log.Printf("[DEBUG] unable to read the digest of %s, only its size was compared: %s", ds.Path(f.destinationFile), err)
+		return nil
+	}
+	if remote == "" || checksumType != f.checksumType {
+		log.Printf("[DEBUG] no %s digest for %s, only its size was compared", f.checksumType, ds.Path(f.destinationFile))
+		return nil
 	}
 
+	if !strings.EqualFold(remote, f.sourceChecksum) {
+		return fmt.Errorf("%s was changed outside of Terraform (%s %s, uploaded %s), not deleting it because delete_guard is set",
+			ds.Path(f.destinationFile), checksumType, remote, f.sourceChecksum)
+	}
 	return nil
 }
 
-func resourceVSphereFileUpdate(d *schema.ResourceData, meta interface{}) error {
-
-	log.Printf("[DEBUG] updating file: %#v", d)
-	if d.HasChange("destination_file") {
-		oldDestinationFile, newDestinationFile := d.GetChange("destination_file")
-		f := file{}
+// isReplacedFile reports whether the datastore file of f was modified after
+// it was last uploaded or refreshed. Either a replacing resource with
+// create_before_destroy has uploaded the same destination, or the file was
+// changed outside of Terraform and refresh marked it to be uploaded again;
+// in both cases the file isn't the one in state and must not be deleted.
+func isReplacedFile(ctx context.Context, ds *object.Datastore, f *file) (bool, error) {
+	if f.lastModified == "" {
+		return false, nil
+	}
 
-		if v, ok := d.GetOk("datacenter"); ok {
-			f.datacenter = v.(string)
+	info, err := statDatastoreFile(ctx, ds, f.browser, f.destinationFile)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			return false, nil
 		}
+		return false, fmt.Errorf("error reading %s: %w", ds.Path(f.destinationFile), err)
+	}
 
-		if v, ok := d.GetOk("datastore"); ok {
-			f.datastore = v.(string)
-		} else {
-			return fmt.Errorf("datastore argument is required")
-		}
+	modified := fileModification(info.GetFileInfo())
+	if modified == "" || modified == f.lastModified {
+		return false, nil
+	}
 
-		if v, ok := d.GetOk("source_file"); ok {
-			f.sourceFile = v.(string)
-		} else {
-			return fmt.Errorf("source_file argument is required")
-		}
+	log.Printf("[INFO] %s was replaced at %s (uploaded at %s), not deleting it",
+		ds.Path(f.destinationFile), modified, f.lastModified)
+	return true, nil
+}
 
-		if v, ok := d.GetOk("destination_file"); ok {
-			f.destinationFile = v.(string)
-		} else {
-			return fmt.Errorf("destination_file argument is required")
-		}
+// cleanupFiles deletes the files in the directory of destinationFile that
+// match glob. Subdirectories are neither searched nor deleted.
+func cleanupFiles(ctx context.Context, fm *object.FileManager, ds *object.Datastore, b *object.HostDatastoreBrowser, dc *object.Datacenter, destinationFile string, glob string) error {
+	directory := path.Dir(destinationFile)
+	if directory == "." {
+		directory = ""
+	}
 
-		client := meta.(*govmomi.Client)
-		dc, err := getDatacenter(client, f.datacenter)
-		if err != nil {
-			return err
+	files, err := searchDatastoreDirectory(ctx, ds, b, directory, glob)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			return nil
 		}
+		return err
+	}
 
-		finder := find.NewFinder(client.Client, true)
-		finder = finder.SetDatacenter(dc)
-
-		ds, err := getDatastore(finder, f.datastore)
-		if err != nil {
-			return fmt.Errorf("error %s", err)
+	for _, file := range files {
+		fi := file.GetFileInfo()
+		if _, ok := file.(*types.FolderFileInfo); ok {
+			log.Printf("[DEBUG] not deleting directory %s matching cleanup_glob %q", fi.Path, glob)
+			continue
 		}
 
-		fm := object.NewFileManager(client.Client)
-		task, err := fm.MoveDatastoreFile(context.TODO(), ds.Path(oldDestinationFile.(string)), dc, ds.Path(newDestinationFile.(string)), dc, true)
+		p := path.Join(directory, fi.Path)
+		log.Printf("[INFO] deleting %s, it matches cleanup_glob %q", ds.Path(p), glob)
+		task, err := fm.DeleteDatastoreFile(ctx, ds.Path(p), dc)
 		if err != nil {
 			return err
 		}
 
-		_, err = task.WaitForResult(context.TODO(), nil)
+		_, err = waitForTask(ctx, task, nil)
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
+// resourceVSphereFileImport imports a datastore file by an ID of the form
+// "[datastore] datacenter/path", or "[datastore] /path" for the default
+// datacenter. source_file is left empty, as it is unknown for imported files.
+func resourceVSphereFileImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	datastore, datacenter, destinationFile, err := parseFileID(d.Id())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	client := meta.(*govmomi.Client)
+	dc, err := getDatacenter(client, datacenter)
+	if err != nil {
+		return nil, fmt.Errorf("error finding datacenter %q: %w", datacenter, err)
+	}
+
+	ds, err := lookupDatastore(client, dc, datastore)
+	if err != nil {
+		return nil, fmt.Errorf("error finding datastore %q: %w", datastore, err)
+	}
+
+	_, err = ds.Stat(context.TODO(), destinationFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import %s: %w", ds.Path(destinationFile), err)
+	}
+
+	setImportedFile(d, datacenter, datastore, destinationFile)
+	return []*schema.ResourceData{d}, nil
 }
 
-func resourceVSphereFileDelete(d *schema.ResourceData, meta interface{}) error {
+// setImportedFile sets the location of an imported file in d, and every
+// argument that has a default to it, so that the next plan doesn't change or
+// replace the file. An imported file has no copies on other datastores, and
+// none of its directories were created by Terraform.
+func setImportedFile(d *schema.ResourceData, datacenter, datastore, destinationFile string) {
+	for k, v := range resourceVSphereFile().Schema {
+		if v.Default != nil {
+			d.Set(k, v.Default)
+		}
+	}
+
+	d.Set("datacenter", datacenter)
+	d.Set("datastore", datastore)
+	d.Set("destination_file", destinationFile)
+	d.Set("source_file", "")
+	d.Set("datastore_copies", []string{})
+	d.Set("datastore_copies_modified", []string{})
+	d.Set("created_directories", []string{})
+}
+
+// datastoreObjectID returns the ID of a file or directory on a datastore,
+// "[datastore] datacenter/path". The datastore name is used as is, like
+// vSphere does in datastore paths, so names with spaces or parentheses need
+// no quoting. parseFileID splits such an ID again.
+func datastoreObjectID(datastore, datacenter, p string) string {
+	return datastorePath(datastore, datacenter+"/"+p)
+}
 
-	log.Printf("[DEBUG] deleting file: %#v", d)
-	f := file{}
+// datastorePath returns the datastore path of p on the datastore named
+// datastore, in the "[datastore] path" form of Datastore.Path, for when only
+// the name of the datastore is at hand.
+func datastorePath(datastore, p string) string {
+	return "[" + datastore + "] " + p
+}
 
-	if v, ok := d.GetOk("datacenter"); ok {
-		f.datacenter = v.(string)
+// inventoryPattern quotes the characters of an inventory name or path that
+// the finder would otherwise treat as a glob, so a datastore named e.g.
+// "ISO [Prod]" is found. Character classes are used rather than backslashes,
+// which are path separators to filepath.Match on Windows.
+func inventoryPattern(name string) string {
+	var buf bytes.Buffer
+	for _, r := range name {
+		switch r {
+		case '*', '?', '[':
+			buf.WriteString("[" + string(r) + "]")
+		default:
+			buf.WriteRune(r)
+		}
 	}
+	return buf.String()
+}
 
-	if v, ok := d.GetOk("datastore"); ok {
-		f.datastore = v.(string)
-	} else {
-		return fmt.Errorf("datastore argument is required")
+// parseFileID splits a vsphere_file ID into datastore, datacenter and path
+func parseFileID(id string) (string, string, string, error) {
+	if !strings.HasPrefix(id, "[") || !strings.Contains(id, "] ") {
+		return "", "", "", fmt.Errorf("invalid file ID %q, expected \"[datastore] datacenter/path\"", id)
 	}
 
-	if v, ok := d.GetOk("source_file"); ok {
-		f.sourceFile = v.(string)
+	end := strings.Index(id, "] ")
+	datastore := id[1:end]
+	rest := id[end+2:]
+
+	var datacenter, filePath string
+	if i := strings.Index(rest, "/"); i >= 0 {
+		datacenter, filePath = rest[:i], rest[i+1:]
 	} else {
-		return fmt.Errorf("source_file argument is required")
+		filePath = rest
+	}
+
+	if datastore == "" || filePath == "" {
+		return "", "", "", fmt.Errorf("invalid file ID %q, expected \"[datastore] datacenter/path\"", id)
 	}
+	return datastore, datacenter, filePath, nil
+}
+
+// getDatastore gets datastore object
+func getDatastore(f *find.Finder, ds string) (*object.Datastore, error) {
+	return getDatastoreContext(context.TODO(), f, ds)
+}
 
-	if v, ok := d.GetOk("destination_file"); ok {
-		f.destinationFile = v.(string)
+// getDatastoreContext is getDatastore, bounded by ctx.
+func getDatastoreContext(ctx context.Context, f *find.Finder, ds string) (*object.Datastore, error) {
+
+	if ds != "" {
+		dso, err := f.Datastore(ctx, inventoryPattern(ds))
+		if _, ok := err.(*find.NotFoundError); ok {
+			return nil, &datastoreNotFoundError{name: ds, available: datastoreNames(ctx, f)}
+		}
+		return dso, err
 	} else {
-		return fmt.Errorf("destination_file argument is required")
+		dso, err := f.DefaultDatastore(ctx)
+		return dso, err
 	}
+}
 
-	client := meta.(*govmomi.Client)
+// maxDatastoreCandidates caps the number of datastores listed in a
+// datastoreNotFoundError.
+const maxDatastoreCandidates = 10
+
+// datastoreNotFoundError is returned for a datastore that doesn't exist. It
+// lists the datastores that do, to help spot a misspelled name.
+type datastoreNotFoundError struct {
+	name      string
+	available []string
+}
+
+func (e *datastoreNotFoundError) Error() string {
+	msg := fmt.Sprintf("datastore '%s' not found", e.name)
+	if len(e.available) == 0 {
+		return msg
+	}
+
+	names := e.available
+	more := ""
+	if len(names) > maxDatastoreCandidates {
+		more = fmt.Sprintf(" and %d more", len(names)-maxDatastoreCandidates)
+		names = names[:maxDatastoreCandidates]
+	}
+	return fmt.Sprintf("%s; available: %s%s", msg, strings.Join(names, ", "), more)
+}
 
-	err := deleteFile(client, &f)
+// isDatastoreNotFoundError reports whether err is, or wraps, a failed
+// datastore lookup.
+func isDatastoreNotFoundError(err error) bool {
+	var notFound *find.NotFoundError
+	var dsNotFound *datastoreNotFoundError
+	return errors.As(err, &notFound) || errors.As(err, &dsNotFound)
+}
+
+// datastoreNames returns the sorted names of the datastores f can find, or
+// nil if they can't be listed.
+func datastoreNames(ctx context.Context, f *find.Finder) []string {
+	dss, err := f.DatastoreList(ctx, "*")
 	if err != nil {
-		return err
+		log.Printf("[DEBUG] unable to list datastores: %s", err)
+		return nil
 	}
 
-	d.SetId("")
-	return nil
+	var names []string
+	for _, ds := range dss {
+		names = append(names, ds.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hostVisibilityTimeout returns how long wait_for_host_visibility waits.
+func hostVisibilityTimeout(d *schema.ResourceData) time.Duration {
+	// Validated by the schema
+	timeout, _ := time.ParseDuration(d.Get("host_visibility_timeout").(string))
+	return timeout
+}
+
+// fileTimeout returns the timeout for operations on a file resource. Resources
+// created before the timeout field existed get the default.
+func fileTimeout(d *schema.ResourceData) time.Duration {
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return defaultFileTimeout
+	}
+	return timeout
 }
 
-func deleteFile(client *govmomi.Client, f *file) error {
+// uploadContext uploads r to u like soap.Client.Upload, but cancels the
+// request when ctx is done. The upload then stops, rather than running on
+// after the operation returned, possibly next to a retry of itself.
+func uploadContext(ctx context.Context, client *govmomi.Client, r io.Reader, u *url.URL, param *soap.Upload) (err error) {
+	if param.Progress != nil {
+		pr := progress.NewReader(param.Progress, r, param.ContentLength)
+		r = pr
+		defer func() {
+			pr.Done(err)
+		}()
+	}
 
-	dc, err := getDatacenter(client, f.datacenter)
+	req, err := http.NewRequest(param.Method, u.String(), r)
 	if err != nil {
 		return err
 	}
+	req.ContentLength = param.ContentLength
+	req.Header.Set("Content-Type", param.Type)
+	for k, v := range param.Headers {
+		req.Header.Add(k, v)
+	}
+	if param.Ticket != nil {
+		req.AddCookie(param.Ticket)
+	}
 
-	finder := find.NewFinder(client.Client, true)
-	finder = finder.SetDatacenter(dc)
+	res, err := ctxhttp.Do(ctx, &client.Client.Client.Client, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
 
-	ds, err := getDatastore(finder, f.datastore)
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	}
+	return errors.New(res.Status)
+}
+
+// downloadFileContext downloads u to the local file like
+// soap.Client.DownloadFile, but cancels the request when ctx is done.
+func downloadFileContext(ctx context.Context, client *govmomi.Client, file string, u *url.URL, param *soap.Download) (err error) {
+	req, err := http.NewRequest(param.Method, u.String(), nil)
 	if err != nil {
-		return fmt.Errorf("error %s", err)
+		return err
+	}
+	if param.Ticket != nil {
+		req.AddCookie(param.Ticket)
 	}
 
-	fm := object.NewFileManager(client.Client)
-	task, err := fm.DeleteDatastoreFile(context.TODO(), ds.Path(f.destinationFile), dc)
+	res, err := ctxhttp.Do(ctx, &client.Client.Client.Client, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New(res.Status)
+	}
+
+	fh, err := os.Create(file)
 	if err != nil {
 		return err
 	}
+	defer fh.Close()
+
+	var r io.Reader = res.Body
+	if param.Progress != nil {
+		pr := progress.NewReader(param.Progress, r, res.ContentLength)
+		r = pr
+		defer func() {
+			pr.Done(err)
+		}()
+	}
 
-	_, err = task.WaitForResult(context.TODO(), nil)
+	_, err = io.Copy(fh, r)
 	if err != nil {
 		return err
 	}
+	return fh.Close()
+}
+
+// uploadWithRetry runs upload until it succeeds, fails with an error that
+// isn't transient, or has been retried the given number of times. The delay
+// between attempts grows exponentially and never outlasts ctx. upload must
+// stop when ctx is done, so that no attempt runs on next to the following.
+func uploadWithRetry(ctx context.Context, retries int, upload func() error) error {
+	backoff := uploadRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := upload()
+		if err == nil || attempt > retries || ctx.Err() != nil || !isTransientError(err) {
+			return err
+		}
+
+		log.Printf("[WARN] upload attempt %d of %d failed, retrying in %s: %s", attempt, retries+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientError reports whether an upload error is worth retrying: network
+// errors and 5xx responses are, client errors and SOAP faults are not. The
+// errors that err wraps are checked as well.
+func isTransientError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch err.(type) {
+		case *url.Error:
+			// Decided by the error it wraps
+			continue
+		case net.Error:
+			return true
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true
+		}
+		if strings.Contains(err.Error(), "connection reset") {
+			return true
+		}
+
+		// Uploads report unexpected responses by their status line
+		var code int
+		if n, _ := fmt.Sscanf(err.Error(), "%d ", &code); n == 1 {
+			return code >= 500 && code < 600
+		}
+	}
+	return false
+}
+
+// timeoutError turns an error caused by an expired operation deadline into
+// one that says so.
+func timeoutError(ctx context.Context, op string, timeout time.Duration, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timeout while waiting for file %s to complete after %s: %w", op, timeout, err)
+	}
+	return err
+}
+
+// isCaseSensitiveDatastore reports whether file names on a datastore of type
+// t are matched case-sensitively by the datastore browser. That is the case
+// for NFS, while VMFS and vSAN ignore case.
+func isCaseSensitiveDatastore(t types.HostFileSystemVolumeFileSystemType) bool {
+	switch t {
+	case types.HostFileSystemVolumeFileSystemTypeNFS, types.HostFileSystemVolumeFileSystemTypeNFS41:
+		return true
+	}
+	return false
+}
+
+// statDatastoreFileFold stats the file in the directory of file whose name
+// equals the base name of file ignoring case. It is used on case-sensitive
+// datastores, where a file that only differs in case from the configured
+// destination_file would otherwise be reported as gone on every refresh.
+func statDatastoreFileFold(ctx context.Context, ds *object.Datastore, b *object.HostDatastoreBrowser, file string) (types.BaseFileInfo, error) {
+	variant, err := datastoreCaseVariant(ctx, ds, b, file)
+	if err != nil {
+		return nil, err
+	}
+	if variant == "" {
+		return nil, datastoreFileNotFoundError{ds.Path(file)}
+	}
+
+	log.Printf("[WARN] %s not found, using %s which only differs in case", ds.Path(file), ds.Path(variant))
+	return statDatastoreFile(ctx, ds, b, variant)
+}
+
+// datastoreCaseVariant returns the path of a file in the directory of file
+// whose name only differs in case from the base name of file, or "" if there
+// is none.
+func datastoreCaseVariant(ctx context.Context, ds *object.Datastore, b *object.HostDatastoreBrowser, file string) (string, error) {
+	directory := path.Dir(file)
+	if directory == "." {
+		directory = ""
+	}
+
+	files, err := searchDatastoreDirectory(ctx, ds, b, directory)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(files))
+	for i, entry := range files {
+		names[i] = entry.GetFileInfo().Path
+	}
+	if name := caseVariant(names, path.Base(file)); name != "" {
+		return path.Join(directory, name), nil
+	}
+	return "", nil
+}
+
+// caseVariant returns the first of names that equals base ignoring case, but
+// not exactly, or "" if there is none.
+func caseVariant(names []string, base string) string {
+	for _, name := range names {
+		if name != base && strings.EqualFold(name, base) {
+			return name
+		}
+	}
+	return ""
+}
+
+// datastoreFileNotFoundError is returned by statDatastoreFile if the
+// directory exists but the file doesn't.
+type datastoreFileNotFoundError struct {
+	path string
+}
+
+func (e datastoreFileNotFoundError) Error() string {
+	return fmt.Sprintf("cannot stat '%s': No such file", e.path)
+}
+
+// isFileNotFoundError reports whether err means that a datastore file or one
+// of its parent directories doesn't exist, as opposed to a failed API call.
+// The errors that err wraps are checked as well; SOAP faults have no exported
+// type for errors.As, so each is checked like err itself.
+func isFileNotFoundError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case object.DatastoreNoSuchFileError, object.DatastoreNoSuchDirectoryError, datastoreFileNotFoundError:
+			return true
+		case task.Error:
+			if _, ok := e.Fault().(*types.FileNotFound); ok {
+				return true
+			}
+		}
+
+		if soap.IsSoapFault(err) {
+			if _, ok := soap.ToSoapFault(err).VimFault().(types.FileNotFound); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeDatastorePath returns the path of a file relative to its datastore.
+// It accepts both bare paths and the "[datastore] path" form shown by the
+// datastore browser, so the datastore isn't added to the path twice. Duplicate
+// and trailing slashes are removed, and backslashes become slashes.
+func normalizeDatastorePath(p string) string {
+	if isDatastorePath(p) {
+		p = strings.TrimSpace(p)
+		if i := strings.Index(p, "]"); i > 0 {
+			p = strings.TrimSpace(p[i+1:])
+		}
+	}
+	if p == "" {
+		return p
+	}
+	return path.Clean(datastoreSlashes(p))
+}
+
+// datastoreSlashes returns p with backslashes, as in paths copied from
+// Windows, replaced by the slashes datastore paths use. Only datastore paths
+// are converted, local paths keep the separator of the OS.
+func datastoreSlashes(p string) string {
+	return strings.Replace(p, "\\", "/", -1)
+}
+
+// validateDatastorePath returns an error if p leads outside of the directory
+// it is relative to, e.g. "../other/x.iso". The path is normalized first, so
+// "[ds] iso/../x.iso" is fine.
+func validateDatastorePath(p string) error {
+	cleaned := normalizeDatastorePath(p)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("%q must not use \"..\" to leave its directory", p)
+	}
 	return nil
 }
 
-// getDatastore gets datastore object
-func getDatastore(f *find.Finder, ds string) (*object.Datastore, error) {
+// normalizePaths normalizes the paths of f that refer to datastore files.
+func (f *file) normalizePaths() {
+	if f.download || f.copyFile {
+		f.sourceFile = normalizeDatastorePath(f.sourceFile)
+	}
+	if !f.download {
+		f.destinationFile = normalizeDatastorePath(f.destinationFile)
+	}
+}
 
-	if ds != "" {
-		dso, err := f.Datastore(context.TODO(), ds)
-		return dso, err
-	} else {
-		dso, err := f.DefaultDatastore(context.TODO())
-		return dso, err
+// isDatastorePath reports whether p uses the "[datastore] path" notation
+// shown by the datastore browser.
+func isDatastorePath(p string) bool {
+	return strings.HasPrefix(strings.TrimSpace(p), "[")
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+func interfacesToStrings(values []interface{}) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = v.(string)
 	}
+	return result
 }
@@ -0,0 +1,181 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+// resourceVSphereFileAbsent makes sure a datastore file does not exist. Its
+// lifecycle is the inverse of vsphere_file: creating it deletes the file, and
+// destroying it leaves the datastore alone.
+func resourceVSphereFileAbsent() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVSphereFileAbsentCreate,
+		Read:   resourceVSphereFileAbsentRead,
+		Delete: resourceVSphereFileAbsentDelete,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"datastore": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if err := validateDatastorePath(v.(string)); err != nil {
+						errors = append(errors, fmt.Errorf("%q %s", k, err))
+					}
+					return
+				},
+			},
+
+			"timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "30m",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					duration, err := time.ParseDuration(value)
+					if err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q cannot be parsed as a duration: %s", k, err))
+					}
+					if duration <= 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be greater than zero", k))
+					}
+					return
+				},
+			},
+
+			"deleted": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceVSphereFileAbsentCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_file_absent"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] making sure file %s on datastore %s is absent", d.Get("path"), d.Get("datastore"))
+	client := meta.(*govmomi.Client)
+	datacenter := d.Get("datacenter").(string)
+	datastore := d.Get("datastore").(string)
+	p := normalizeDatastorePath(d.Get("path").(string))
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	deleted, err := ensureFileAbsent(ctx, client, datacenter, datastore, p)
+	if err != nil {
+		return timeoutError(ctx, "create", timeout, err)
+	}
+
+	d.SetId(datastoreObjectID(datastore, datacenter, p))
+	d.Set("deleted", deleted)
+
+	return nil
+}
+
+// ensureFileAbsent deletes the file p of datastore, if it exists. It returns
+// whether there was a file to delete.
+func ensureFileAbsent(ctx context.Context, client *govmomi.Client, datacenter, datastore, p string) (bool, error) {
+
+	dc, err := getDatacenterContext(ctx, client, datacenter)
+	if err != nil {
+		return false, fmt.Errorf("error finding datacenter %q: %s", datacenter, err)
+	}
+
+	ds, err := lookupDatastoreContext(ctx, client, dc, datastore)
+	if err != nil {
+		return false, fmt.Errorf("error finding datastore %q: %s", datastore, err)
+	}
+
+	_, err = ds.Stat(ctx, p)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			log.Printf("[DEBUG] file %s is already absent", ds.Path(p))
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking file %s: %s", ds.Path(p), err)
+	}
+
+	log.Printf("[INFO] deleting file %s", ds.Path(p))
+	fm := object.NewFileManager(client.Client)
+	task, err := fm.DeleteDatastoreFile(ctx, ds.Path(p), dc)
+	if err != nil {
+		return false, fmt.Errorf("error deleting file %s: %s", ds.Path(p), err)
+	}
+
+	_, err = waitForTask(ctx, task, nil)
+	if err != nil && !isFileNotFoundError(err) {
+		return false, fmt.Errorf("error deleting file %s: %s", ds.Path(p), err)
+	}
+	return true, nil
+}
+
+func resourceVSphereFileAbsentRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading file absent %s", d.Id())
+	client := meta.(*govmomi.Client)
+	datacenter := d.Get("datacenter").(string)
+	datastore := d.Get("datastore").(string)
+	p := normalizeDatastorePath(d.Get("path").(string))
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dc, err := getDatacenterContext(ctx, client, datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", datacenter, err)
+	}
+
+	ds, err := lookupDatastoreContext(ctx, client, dc, datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", datastore, err)
+	}
+
+	// Refresh must not change the datastore, so a file that reappeared is
+	// only deleted by the next apply.
+	_, err = ds.Stat(ctx, p)
+	if err == nil {
+		log.Printf("[INFO] file %s exists again", ds.Path(p))
+		d.SetId("")
+		return nil
+	}
+	if !isFileNotFoundError(err) {
+		return timeoutError(ctx, "read", timeout, err)
+	}
+
+	return nil
+}
+
+func resourceVSphereFileAbsentDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[DEBUG] removing file absent %s from state, the datastore is left alone", d.Id())
+	d.SetId("")
+	return nil
+}
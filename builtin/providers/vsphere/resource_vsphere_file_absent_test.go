@@ -0,0 +1,130 @@
+package vsphere
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/net/context"
+)
+
+// Deleting a file that exists, then accepting one that is already absent
+func TestAccVSphereFileAbsent_basic(t *testing.T) {
+	testVmdkFile := "/tmp/tf_test_absent.vmdk"
+	err := ioutil.WriteFile(testVmdkFile, []byte("# Disk DescriptorFile\n"), 0644)
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+	defer os.Remove(testVmdkFile)
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	config := fmt.Sprintf(
+		testAccCheckVSphereFileAbsentConfig,
+		datacenter,
+		datastore,
+		"tf_test_absent.vmdk",
+	)
+	resourceName := "vsphere_file_absent.foo"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				PreConfig: func() {
+					err := testAccUploadVSphereFile(datacenter, datastore, testVmdkFile, "tf_test_absent.vmdk")
+					if err != nil {
+						t.Fatalf("error uploading test file: %s", err)
+					}
+				},
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereFileAbsent(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "deleted", "true"),
+				),
+			},
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereFileAbsent(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "deleted", "true"),
+				),
+			},
+			{
+				// A path that never existed needs no deleting
+				Config: fmt.Sprintf(
+					testAccCheckVSphereFileAbsentConfig,
+					datacenter,
+					datastore,
+					"tf_test_never_uploaded.vmdk",
+				),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVSphereFileAbsent(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "deleted", "false"),
+				),
+			},
+		},
+	})
+}
+
+func testAccUploadVSphereFile(datacenter, datastore, sourceFile, destinationFile string) error {
+	client := testAccProvider.Meta().(*govmomi.Client)
+
+	dc, err := getDatacenter(client, datacenter)
+	if err != nil {
+		return err
+	}
+
+	ds, err := lookupDatastore(client, dc, datastore)
+	if err != nil {
+		return err
+	}
+
+	return ds.UploadFile(context.TODO(), sourceFile, destinationFile, &soap.DefaultUpload)
+}
+
+func testAccCheckVSphereFileAbsent(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*govmomi.Client)
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		ds, err := lookupDatastore(client, dc, rs.Primary.Attributes["datastore"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		p := rs.Primary.Attributes["path"]
+		_, err = ds.Stat(context.TODO(), p)
+		if err == nil {
+			return fmt.Errorf("file %s still exists", ds.Path(p))
+		}
+		if !isFileNotFoundError(err) {
+			return err
+		}
+
+		return nil
+	}
+}
+
+const testAccCheckVSphereFileAbsentConfig = `
+resource "vsphere_file_absent" "foo" {
+	datacenter = "%s"
+	datastore = "%s"
+	path = "%s"
+}
+`
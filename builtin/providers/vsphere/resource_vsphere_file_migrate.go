@@ -0,0 +1,74 @@
+package vsphere
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func resourceVSphereFileMigrateState(
+	v int, is *terraform.InstanceState, meta interface{}) (*terraform.InstanceState, error) {
+	if is.Empty() {
+		log.Println("[DEBUG] Empty InstanceState; nothing to migrate.")
+		return is, nil
+	}
+
+	switch v {
+	case 0:
+		log.Println("[INFO] Found VSphere File State v0; migrating to v1")
+		is, err := migrateVSphereFileStateV0toV1(is)
+		if err != nil {
+			return is, err
+		}
+		return is, nil
+	default:
+		return is, fmt.Errorf("Unexpected schema version: %d", v)
+	}
+}
+
+// migrateVSphereFileStateV0toV1 rebuilds the ID of files created before IDs
+// were built by datastoreObjectID. Those IDs hold destination_file as it was
+// configured, e.g. "[ds] dc/iso/../x.iso" or "[ds] dc/[ds] iso/x.iso", and the
+// datastore cluster rather than the member holding the file.
+func migrateVSphereFileStateV0toV1(is *terraform.InstanceState) (*terraform.InstanceState, error) {
+	if is.Empty() || is.Attributes == nil {
+		log.Println("[DEBUG] Empty VSphere File State; nothing to migrate.")
+		return is, nil
+	}
+
+	datastore, datacenter, p, err := parseFileIDV0(is.ID, is.Attributes["datacenter"])
+	if err != nil {
+		// Leave IDs that can't be parsed alone rather than fail the upgrade
+		log.Printf("[WARN] not migrating ID of vsphere_file: %s", err)
+		return is, nil
+	}
+
+	if member := is.Attributes["datastore_member"]; member != "" {
+		datastore = member
+	}
+	if is.Attributes["download"] != "true" {
+		p = normalizeDatastorePath(p)
+	}
+
+	id := datastoreObjectID(datastore, datacenter, p)
+	log.Printf("[DEBUG] ID before migration: %s, after: %s", is.ID, id)
+	is.ID = id
+	return is, nil
+}
+
+// parseFileIDV0 splits a v0 file ID like parseFileID. A datacenter given by
+// its inventory path also contains "/", so the datacenter in state is
+// matched first.
+func parseFileIDV0(id, datacenter string) (string, string, string, error) {
+	if datacenter != "" && strings.HasPrefix(id, "[") {
+		if end := strings.Index(id, "] "); end > 0 {
+			rest := id[end+2:]
+			if strings.HasPrefix(rest, datacenter+"/") {
+				return id[1:end], datacenter, strings.TrimPrefix(rest, datacenter+"/"), nil
+			}
+		}
+	}
+	return parseFileID(id)
+}
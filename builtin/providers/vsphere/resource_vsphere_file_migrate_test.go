@@ -0,0 +1,115 @@
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestVSphereFileMigrateState(t *testing.T) {
+	cases := map[string]struct {
+		StateVersion int
+		ID           string
+		Attributes   map[string]string
+		Expected     string
+	}{
+		"unchanged": {
+			StateVersion: 0,
+			ID:           "[datastore1] dc1/iso/ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter":       "dc1",
+				"destination_file": "iso/ubuntu.iso",
+			},
+			Expected: "[datastore1] dc1/iso/ubuntu.iso",
+		},
+		"unclean destination_file": {
+			StateVersion: 0,
+			ID:           "[datastore1] dc1/iso/./old/../ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter":       "dc1",
+				"destination_file": "iso/./old/../ubuntu.iso",
+			},
+			Expected: "[datastore1] dc1/iso/ubuntu.iso",
+		},
+		"datastore path as destination_file": {
+			StateVersion: 0,
+			ID:           "[datastore1] dc1/[datastore1] iso/ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter":       "dc1",
+				"destination_file": "[datastore1] iso/ubuntu.iso",
+			},
+			Expected: "[datastore1] dc1/iso/ubuntu.iso",
+		},
+		"datastore cluster": {
+			StateVersion: 0,
+			ID:           "[cluster1] dc1/iso/ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter":       "dc1",
+				"datastore_member": "datastore2",
+			},
+			Expected: "[datastore2] dc1/iso/ubuntu.iso",
+		},
+		"datacenter in a folder": {
+			StateVersion: 0,
+			ID:           "[datastore1] emea/dc1/iso/../ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter": "emea/dc1",
+			},
+			Expected: "[datastore1] emea/dc1/ubuntu.iso",
+		},
+		"download": {
+			StateVersion: 0,
+			ID:           "[datastore1] dc1//tmp/./ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter": "dc1",
+				"download":   "true",
+			},
+			Expected: "[datastore1] dc1//tmp/./ubuntu.iso",
+		},
+		"invalid ID": {
+			StateVersion: 0,
+			ID:           "ubuntu.iso",
+			Attributes: map[string]string{
+				"datacenter": "dc1",
+			},
+			Expected: "ubuntu.iso",
+		},
+	}
+
+	for tn, tc := range cases {
+		is := &terraform.InstanceState{
+			ID:         tc.ID,
+			Attributes: tc.Attributes,
+		}
+		is, err := resourceVSphereFileMigrateState(tc.StateVersion, is, nil)
+
+		if err != nil {
+			t.Fatalf("bad: %s, err: %#v", tn, err)
+		}
+		if is.ID != tc.Expected {
+			t.Fatalf("bad: %s\n\n expected ID %q, got %q", tn, tc.Expected, is.ID)
+		}
+	}
+}
+
+func TestVSphereFileMigrateState_empty(t *testing.T) {
+	var is *terraform.InstanceState
+
+	// should handle nil
+	is, err := resourceVSphereFileMigrateState(0, is, nil)
+
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if is != nil {
+		t.Fatalf("expected nil instancestate, got: %#v", is)
+	}
+
+	// should handle non-nil but empty
+	is = &terraform.InstanceState{}
+	is, err = resourceVSphereFileMigrateState(0, is, nil)
+
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+}
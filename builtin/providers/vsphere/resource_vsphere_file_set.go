@@ -0,0 +1,396 @@
+package vsphere
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"golang.org/x/net/context"
+)
+
+// fileSetEntry is one file of a vsphere_file_set
+type fileSetEntry struct {
+	datastore  string
+	path       string
+	sourceFile string
+}
+
+// key identifies the entry in the uploaded list of the state.
+func (e fileSetEntry) key() string {
+	return datastorePath(e.datastore, e.path)
+}
+
+type fileSet struct {
+	datacenter  string
+	parallelism int
+	entries     []fileSetEntry
+	uploaded    []string
+}
+
+func resourceVSphereFileSet() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVSphereFileSetCreate,
+		Read:   resourceVSphereFileSetRead,
+		Delete: resourceVSphereFileSetDelete,
+
+		Schema: map[string]*schema.Schema{
+			"datacenter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"file": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"datastore": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+
+						"path": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+								if err := validateDatastorePath(v.(string)); err != nil {
+									errors = append(errors, fmt.Errorf("%q %s", k, err))
+								}
+								return
+							},
+						},
+
+						"source_file": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+
+			"parallelism": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: true,
+				Default:  4,
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 1 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be at least 1", k))
+					}
+					return
+				},
+			},
+
+			"timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "30m",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+					duration, err := time.ParseDuration(value)
+					if err != nil {
+						errors = append(errors, fmt.Errorf(
+							"%q cannot be parsed as a duration: %s", k, err))
+					}
+					if duration <= 0 {
+						errors = append(errors, fmt.Errorf(
+							"%q must be greater than zero", k))
+					}
+					return
+				},
+			},
+
+			"uploaded": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// expandFileSet reads a vsphere_file_set from its resource data.
+func expandFileSet(d *schema.ResourceData) *fileSet {
+	s := &fileSet{
+		datacenter:  d.Get("datacenter").(string),
+		parallelism: d.Get("parallelism").(int),
+	}
+
+	for _, v := range d.Get("file").([]interface{}) {
+		m := v.(map[string]interface{})
+		s.entries = append(s.entries, fileSetEntry{
+			datastore:  m["datastore"].(string),
+			path:       normalizeDatastorePath(m["path"].(string)),
+			sourceFile: m["source_file"].(string),
+		})
+	}
+
+	for _, v := range d.Get("uploaded").([]interface{}) {
+		s.uploaded = append(s.uploaded, v.(string))
+	}
+	return s
+}
+
+// fileSetID derives the ID of a set from its files.
+func fileSetID(s *fileSet) string {
+	var buf bytes.Buffer
+	buf.WriteString(s.datacenter)
+	for _, e := range s.entries {
+		buf.WriteString("\n" + e.key())
+	}
+	return fmt.Sprintf("fileset-%d", hashcode.String(buf.String()))
+}
+
+func resourceVSphereFileSetCreate(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "create vsphere_file_set"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] creating file set of %d files", len(d.Get("file").([]interface{})))
+	client := meta.(*govmomi.Client)
+	s := expandFileSet(d)
+
+	seen := make(map[string]bool)
+	for _, e := range s.entries {
+		if seen[e.key()] {
+			return fmt.Errorf("%s is listed more than once", e.key())
+		}
+		seen[e.key()] = true
+
+		if _, err := validateSourceFile(e.sourceFile); err != nil {
+			return err
+		}
+	}
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := createFileSet(ctx, client, s)
+
+	// Record whatever was uploaded, so a failed upload can still be destroyed.
+	d.Set("uploaded", s.uploaded)
+	if err != nil {
+		if len(s.uploaded) > 0 {
+			d.SetId(fileSetID(s))
+		}
+		return timeoutError(ctx, "create", timeout, err)
+	}
+
+	d.SetId(fileSetID(s))
+	log.Printf("[INFO] Uploaded %d files", len(s.uploaded))
+
+	return resourceVSphereFileSetRead(d, meta)
+}
+
+func createFileSet(ctx context.Context, client *govmomi.Client, s *fileSet) error {
+
+	dc, err := getDatacenterContext(ctx, client, s.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", s.datacenter, err)
+	}
+
+	datastores := make(map[string]*object.Datastore)
+	var uploads []fileUpload
+	for _, e := range s.entries {
+		ds, err := lookupDatastoreContext(ctx, client, dc, e.datastore)
+		if err != nil {
+			return fmt.Errorf("error finding datastore %q: %s", e.datastore, err)
+		}
+		datastores[e.key()] = ds
+		uploads = append(uploads, fileUpload{localPath: e.sourceFile, remotePath: e.key()})
+	}
+
+	uploaded, err := uploadFiles(ctx, s.parallelism, uploads, func(ctx context.Context, upload fileUpload) error {
+		ds := datastores[upload.remotePath]
+		remotePath := normalizeDatastorePath(upload.remotePath)
+		log.Printf("[DEBUG] uploading %s to %s", upload.localPath, ds.Path(remotePath))
+		return uploadDatastoreFile(ctx, client, ds, dc, upload.localPath, remotePath)
+	})
+	s.uploaded = uploaded
+	if err != nil {
+		return fmt.Errorf("uploaded %d of %d files%s; %s",
+			len(uploaded), len(s.entries), listFileSetKeys(uploaded), err)
+	}
+	return nil
+}
+
+func resourceVSphereFileSetRead(d *schema.ResourceData, meta interface{}) error {
+
+	log.Printf("[DEBUG] reading file set %s", d.Id())
+	client := meta.(*govmomi.Client)
+	s := expandFileSet(d)
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dc, err := getDatacenterContext(ctx, client, s.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", s.datacenter, err)
+	}
+
+	// After a partial upload, only the uploaded files are expected to exist.
+	uploaded := make(map[string]bool)
+	for _, key := range s.uploaded {
+		uploaded[key] = true
+	}
+
+	for _, e := range s.entries {
+		if !uploaded[e.key()] {
+			continue
+		}
+
+		ds, err := lookupDatastoreContext(ctx, client, dc, e.datastore)
+		if err != nil {
+			return fmt.Errorf("error finding datastore %q: %s", e.datastore, err)
+		}
+
+		_, err = ds.Stat(ctx, e.path)
+		if err != nil {
+			if isFileNotFoundError(err) {
+				log.Printf("[INFO] file %s of the set is gone", ds.Path(e.path))
+				d.SetId("")
+				return nil
+			}
+			return timeoutError(ctx, "read", timeout, err)
+		}
+	}
+
+	return nil
+}
+
+func resourceVSphereFileSetDelete(d *schema.ResourceData, meta interface{}) error {
+
+	if err := checkWritable(meta, "delete vsphere_file_set"); err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] deleting file set %s", d.Id())
+	client := meta.(*govmomi.Client)
+	s := expandFileSet(d)
+
+	timeout := fileTimeout(d)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := deleteFileSet(ctx, client, s)
+
+	// Keep the files that couldn't be deleted, to try again.
+	d.Set("uploaded", s.uploaded)
+	if err != nil {
+		return timeoutError(ctx, "delete", timeout, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// deleteFileSet deletes the uploaded files of s. Unlike an upload, a failure
+// doesn't stop the other files from being deleted. The files that could not
+// be deleted are left in s.uploaded.
+func deleteFileSet(ctx context.Context, client *govmomi.Client, s *fileSet) error {
+
+	dc, err := getDatacenterContext(ctx, client, s.datacenter)
+	if err != nil {
+		return fmt.Errorf("error finding datacenter %q: %s", s.datacenter, err)
+	}
+
+	uploaded := make(map[string]bool)
+	for _, key := range s.uploaded {
+		uploaded[key] = true
+	}
+
+	fm := object.NewFileManager(client.Client)
+	var deleted []string
+	failed := make(map[string]error)
+	for _, e := range s.entries {
+		if !uploaded[e.key()] {
+			continue
+		}
+
+		err := deleteFileSetEntry(ctx, client, fm, dc, e)
+		if err != nil {
+			failed[e.key()] = err
+			continue
+		}
+		deleted = append(deleted, e.key())
+	}
+
+	s.uploaded = nil
+	for key := range failed {
+		s.uploaded = append(s.uploaded, key)
+	}
+	sort.Strings(s.uploaded)
+
+	return fileSetDeleteError(deleted, failed)
+}
+
+func deleteFileSetEntry(ctx context.Context, client *govmomi.Client, fm *object.FileManager, dc *object.Datacenter, e fileSetEntry) error {
+	ds, err := lookupDatastoreContext(ctx, client, dc, e.datastore)
+	if err != nil {
+		return fmt.Errorf("error finding datastore %q: %s", e.datastore, err)
+	}
+
+	_, err = ds.Stat(ctx, e.path)
+	if err != nil {
+		if isFileNotFoundError(err) {
+			log.Printf("[DEBUG] file %s is already gone", ds.Path(e.path))
+			return nil
+		}
+		return err
+	}
+
+	task, err := fm.DeleteDatastoreFile(ctx, ds.Path(e.path), dc)
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForTask(ctx, task, nil)
+	return err
+}
+
+// fileSetDeleteError reports which files of a set were deleted and which
+// were not, or returns nil if none failed.
+func fileSetDeleteError(deleted []string, failed map[string]error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var keys []string
+	for key := range failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []string
+	for _, key := range keys {
+		errs = append(errs, fmt.Sprintf("%s: %s", key, failed[key]))
+	}
+	return fmt.Errorf("deleted %d of %d files%s; failed to delete %s",
+		len(deleted), len(deleted)+len(failed), listFileSetKeys(deleted), strings.Join(errs, "; "))
+}
+
+// listFileSetKeys formats the files an operation succeeded for, as a
+// parenthesized list following a count.
+func listFileSetKeys(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(keys, ", ") + ")"
+}
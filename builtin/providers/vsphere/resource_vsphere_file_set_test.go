@@ -0,0 +1,143 @@
+package vsphere
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/vmware/govmomi"
+	"golang.org/x/net/context"
+)
+
+// Upload of two files as one set
+func TestAccVSphereFileSet_basic(t *testing.T) {
+	sourceDirectory, err := ioutil.TempDir("", "tf_test_file_set")
+	if err != nil {
+		t.Errorf("error %s", err)
+		return
+	}
+	defer os.RemoveAll(sourceDirectory)
+
+	for _, name := range []string{"ks.cfg", "post.sh"} {
+		err = ioutil.WriteFile(filepath.Join(sourceDirectory, name), []byte("# "+name+"\n"), 0644)
+		if err != nil {
+			t.Errorf("error %s", err)
+			return
+		}
+	}
+
+	datacenter := os.Getenv("VSPHERE_DATACENTER")
+	datastore := os.Getenv("VSPHERE_DATASTORE")
+	resourceName := "vsphere_file_set.basic"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVSphereFileSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(
+					testAccCheckVSphereFileSetConfig,
+					datacenter,
+					datastore,
+					filepath.Join(sourceDirectory, "ks.cfg"),
+					datastore,
+					filepath.Join(sourceDirectory, "post.sh"),
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "uploaded.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "uploaded.0", fmt.Sprintf("[%s] tf_test_ks.cfg", datastore)),
+					resource.TestCheckResourceAttr(resourceName, "uploaded.1", fmt.Sprintf("[%s] tf_test_post.sh", datastore)),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVSphereFileSetDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*govmomi.Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "vsphere_file_set" {
+			continue
+		}
+
+		dc, err := getDatacenter(client, rs.Primary.Attributes["datacenter"])
+		if err != nil {
+			return fmt.Errorf("error %s", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			prefix := fmt.Sprintf("file.%d.", i)
+			ds, err := lookupDatastore(client, dc, rs.Primary.Attributes[prefix+"datastore"])
+			if err != nil {
+				return fmt.Errorf("error %s", err)
+			}
+
+			p := rs.Primary.Attributes[prefix+"path"]
+			_, err = ds.Stat(context.TODO(), p)
+			if err == nil {
+				return fmt.Errorf("file %s still exists", ds.Path(p))
+			}
+			if !isFileNotFoundError(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+const testAccCheckVSphereFileSetConfig = `
+resource "vsphere_file_set" "basic" {
+	datacenter = "%s"
+
+	file {
+		datastore = "%s"
+		path = "tf_test_ks.cfg"
+		source_file = "%s"
+	}
+
+	file {
+		datastore = "%s"
+		path = "tf_test_post.sh"
+		source_file = "%s"
+	}
+}
+`
+
+func TestFileSetDeleteError(t *testing.T) {
+	if err := fileSetDeleteError([]string{"[ds1] a.iso"}, nil); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	err := fileSetDeleteError([]string{"[ds1] a.iso"}, map[string]error{
+		"[ds2] c.iso": errors.New("permission denied"),
+		"[ds1] b.iso": errors.New("file is locked"),
+	})
+	expected := "deleted 1 of 3 files ([ds1] a.iso); failed to delete [ds1] b.iso: file is locked; [ds2] c.iso: permission denied"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestFileSetID(t *testing.T) {
+	s := &fileSet{
+		datacenter: "dc1",
+		entries: []fileSetEntry{
+			{datastore: "ds1", path: "iso/a.iso"},
+			{datastore: "ds2", path: "iso/b.iso"},
+		},
+	}
+	id := fileSetID(s)
+
+	s.entries[1].path = "iso/c.iso"
+	if fileSetID(s) == id {
+		t.Fatalf("expected the ID to change with the files, got %s twice", id)
+	}
+}
@@ -48,6 +48,10 @@ func resourceVSphereFolder() *schema.Resource {
 
 func resourceVSphereFolderCreate(d *schema.ResourceData, meta interface{}) error {
 
+	if err := checkWritable(meta, "create vsphere_folder"); err != nil {
+		return err
+	}
+
 	client := meta.(*govmomi.Client)
 
 	f := folder{
@@ -145,6 +149,10 @@ func resourceVSphereFolderRead(d *schema.ResourceData, meta interface{}) error {
 
 func resourceVSphereFolderDelete(d *schema.ResourceData, meta interface{}) error {
 
+	if err := checkWritable(meta, "delete vsphere_folder"); err != nil {
+		return err
+	}
+
 	f := folder{
 		path:         strings.TrimRight(d.Get("path").(string), "/"),
 		existingPath: d.Get("existing_path").(string),
@@ -224,14 +232,32 @@ func deleteFolder(client *govmomi.Client, f *folder) error {
 	return nil
 }
 
-// getDatacenter gets datacenter object
+// esxiDatacenter is the name of the implicit datacenter of a standalone ESXi host
+const esxiDatacenter = "ha-datacenter"
+
+// getDatacenter gets datacenter object, falling back to the default
+// datacenter when no name is given
 func getDatacenter(c *govmomi.Client, dc string) (*object.Datacenter, error) {
-	finder := find.NewFinder(c.Client, true)
-	if dc != "" {
-		d, err := finder.Datacenter(context.TODO(), dc)
-		return d, err
-	} else {
-		d, err := finder.DefaultDatacenter(context.TODO())
-		return d, err
-	}
+	return getDatacenterContext(context.TODO(), c, dc)
+}
+
+// getDatacenterContext is getDatacenter, bounded by ctx.
+func getDatacenterContext(ctx context.Context, c *govmomi.Client, dc string) (*object.Datacenter, error) {
+	return clientLookupCache(c).datacenter(dc, func() (*object.Datacenter, error) {
+		finder := find.NewFinder(c.Client, true)
+		if dc == "" && !c.IsVC() {
+			// ESXi hosts without vCenter only have their implicit datacenter
+			dc = esxiDatacenter
+		}
+		if dc != "" {
+			d, err := finder.Datacenter(ctx, dc)
+			return d, err
+		} else {
+			d, err := finder.DefaultDatacenter(ctx)
+			if _, ok := err.(*find.DefaultMultipleFoundError); ok {
+				return nil, fmt.Errorf("datacenter must be set, there is more than one datacenter on this vSphere server")
+			}
+			return d, err
+		}
+	})
 }
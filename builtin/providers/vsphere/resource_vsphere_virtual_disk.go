@@ -88,6 +88,10 @@ func resourceVSphereVirtualDisk() *schema.Resource {
 }
 
 func resourceVSphereVirtualDiskCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := checkWritable(meta, "create vsphere_virtual_disk"); err != nil {
+		return err
+	}
+
 	log.Printf("[INFO] Creating Virtual Disk")
 	client := meta.(*govmomi.Client)
 
@@ -115,7 +119,7 @@ func resourceVSphereVirtualDiskCreate(d *schema.ResourceData, meta interface{})
 		vDisk.datastore = v.(string)
 	}
 
-	diskPath := fmt.Sprintf("[%v] %v", vDisk.datastore, vDisk.vmdkPath)
+	diskPath := datastorePath(vDisk.datastore, vDisk.vmdkPath)
 
 	err := createHardDisk(client, vDisk.size, diskPath, vDisk.initType, vDisk.adapterType, vDisk.datacenter)
 	if err != nil {
@@ -192,6 +196,10 @@ func resourceVSphereVirtualDiskRead(d *schema.ResourceData, meta interface{}) er
 }
 
 func resourceVSphereVirtualDiskDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkWritable(meta, "delete vsphere_virtual_disk"); err != nil {
+		return err
+	}
+
 	client := meta.(*govmomi.Client)
 
 	vDisk := virtualDisk{}
@@ -207,7 +215,7 @@ func resourceVSphereVirtualDiskDelete(d *schema.ResourceData, meta interface{})
 	if err != nil {
 		return err
 	}
-	diskPath := fmt.Sprintf("[%v] %v", vDisk.datastore, vDisk.vmdkPath)
+	diskPath := datastorePath(vDisk.datastore, vDisk.vmdkPath)
 
 	virtualDiskManager := object.NewVirtualDiskManager(client.Client)
 
@@ -216,7 +224,7 @@ func resourceVSphereVirtualDiskDelete(d *schema.ResourceData, meta interface{})
 		return err
 	}
 
-	_, err = task.WaitForResult(context.TODO(), nil)
+	_, err = waitForTask(context.TODO(), task, nil)
 	if err != nil {
 		log.Printf("[INFO] Failed to delete disk:  %v", err)
 		return err
@@ -248,7 +256,7 @@ func createHardDisk(client *govmomi.Client, size int, diskPath string, diskType
 		return err
 	}
 
-	_, err = task.WaitForResult(context.TODO(), nil)
+	_, err = waitForTask(context.TODO(), task, nil)
 	if err != nil {
 		log.Printf("[INFO] Failed to create disk:  %v", err)
 		return err
@@ -453,6 +453,10 @@ func resourceVSphereVirtualMachine() *schema.Resource {
 }
 
 func resourceVSphereVirtualMachineUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := checkWritable(meta, "update vsphere_virtual_machine"); err != nil {
+		return err
+	}
+
 	// flag if changes have to be applied
 	hasChanges := false
 	// flag if changes have to be done when powered off
@@ -629,6 +633,10 @@ func resourceVSphereVirtualMachineUpdate(d *schema.ResourceData, meta interface{
 }
 
 func resourceVSphereVirtualMachineCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := checkWritable(meta, "create vsphere_virtual_machine"); err != nil {
+		return err
+	}
+
 	client := meta.(*govmomi.Client)
 
 	vm := virtualMachine{
@@ -1076,6 +1084,10 @@ func resourceVSphereVirtualMachineRead(d *schema.ResourceData, meta interface{})
 }
 
 func resourceVSphereVirtualMachineDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkWritable(meta, "delete vsphere_virtual_machine"); err != nil {
+		return err
+	}
+
 	client := meta.(*govmomi.Client)
 	dc, err := getDatacenter(client, d.Get("datacenter").(string))
 	if err != nil {
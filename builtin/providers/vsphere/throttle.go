@@ -0,0 +1,53 @@
+package vsphere
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// throttledReader limits the rate at which an upload reads its source to
+// bytesPerSecond, averaged since the first read.
+type throttledReader struct {
+	ctx            context.Context
+	r              io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+// throttle returns r limited to bytesPerSecond, or r itself if
+// bytesPerSecond is not positive. Waiting for the rate stops with an error
+// when ctx is done.
+func throttle(ctx context.Context, r io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, bytesPerSecond: bytesPerSecond}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	// Read at most a second worth of data at a time, so the rate stays
+	// even with large buffers.
+	if int64(len(p)) > t.bytesPerSecond {
+		p = p[:t.bytesPerSecond]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	due := time.Duration(float64(t.read) / float64(t.bytesPerSecond) * float64(time.Second))
+	if wait := due - time.Since(t.start); wait > 0 {
+		select {
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,43 @@
+package vsphere
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestThrottle(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 500)
+
+	r := bytes.NewReader(data)
+	if throttle(context.Background(), r, 0) != r {
+		t.Fatal("expected no throttling without a bandwidth")
+	}
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(throttle(context.Background(), bytes.NewReader(data), 2000))
+	if err != nil {
+		t.Fatalf("error %s", err)
+	}
+	if len(read) != len(data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), len(read))
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected 500 bytes at 2000 bytes/s to take about 250ms, took %s", elapsed)
+	}
+
+	// Waiting stops when the upload is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = ioutil.ReadAll(throttle(ctx, bytes.NewReader(data), 100))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to stop the read, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the read to stop at the deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,105 @@
+package vsphere
+
+import (
+	"log"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/progress"
+	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/net/context"
+)
+
+// taskPollConfig controls how waitForTask waits for a vSphere task.
+type taskPollConfig struct {
+	// interval is the delay between the first polls of a task. If it is 0,
+	// the task is not polled but watched with the property collector.
+	interval time.Duration
+
+	// maxInterval caps the delay, which doubles after every poll.
+	maxInterval time.Duration
+}
+
+// waitForTask waits for t to finish like Task.WaitForResult. If the provider
+// is configured with task_poll_interval, the task is polled with exponential
+// backoff instead, which puts less load on rate-limited vCenters.
+func waitForTask(ctx context.Context, t *object.Task, s progress.Sinker) (*types.TaskInfo, error) {
+	cfg := getClientSettings(t.Client()).taskPoll
+	if cfg.interval <= 0 {
+		return t.WaitForResult(ctx, s)
+	}
+
+	var ch chan<- progress.Report
+	if s != nil {
+		ch = s.Sink()
+		defer close(ch)
+	}
+
+	collector := property.DefaultCollector(t.Client())
+	interval := cfg.interval
+	for {
+		var mt mo.Task
+		err := collector.RetrieveOne(ctx, t.Reference(), []string{"info"}, &mt)
+		if err != nil {
+			return nil, err
+		}
+
+		info := mt.Info
+		r := taskReport{&info}
+		switch info.State {
+		case types.TaskInfoStateSuccess, types.TaskInfoStateError:
+			if ch != nil {
+				ch <- r
+			}
+			return &info, r.Error()
+		}
+
+		if ch != nil {
+			// Like task.Wait, don't block on intermediate reports
+			select {
+			case ch <- r:
+			default:
+			}
+		}
+
+		log.Printf("[DEBUG] task %s is %s, polling again in %s", t.Reference().Value, info.State, interval)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		interval = nextPollInterval(interval, cfg.maxInterval)
+	}
+}
+
+// nextPollInterval doubles interval, up to max.
+func nextPollInterval(interval, max time.Duration) time.Duration {
+	interval *= 2
+	if max > 0 && interval > max {
+		return max
+	}
+	return interval
+}
+
+// taskReport reports the progress of a polled task to a progress.Sinker.
+type taskReport struct {
+	info *types.TaskInfo
+}
+
+func (r taskReport) Percentage() float32 {
+	return float32(r.info.Progress)
+}
+
+func (r taskReport) Detail() string {
+	return ""
+}
+
+func (r taskReport) Error() error {
+	if r.info.Error != nil {
+		return task.Error{LocalizedMethodFault: r.info.Error}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package vsphere
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	cases := []struct {
+		interval, max, expected time.Duration
+	}{
+		{time.Second, 30 * time.Second, 2 * time.Second},
+		{20 * time.Second, 30 * time.Second, 30 * time.Second},
+		{30 * time.Second, 30 * time.Second, 30 * time.Second},
+		{time.Minute, 0, 2 * time.Minute},
+	}
+
+	for _, tc := range cases {
+		actual := nextPollInterval(tc.interval, tc.max)
+		if actual != tc.expected {
+			t.Errorf("nextPollInterval(%s, %s): expected %s, got %s", tc.interval, tc.max, tc.expected, actual)
+		}
+	}
+}